@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"coursify-api/models"
+	"coursify-api/routes"
+	"coursify-api/tokens"
 	"database/sql"
 	"encoding/base64"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
 	"strings"
 )
 
-func searchCredential(authValue string, db *sql.DB) (int64, bool) {
+func searchCredential(ctx context.Context, authValue string, db *sql.DB) (int64, bool) {
 	if authValue == "" {
 		return 0, false
 	}
@@ -29,7 +34,7 @@ func searchCredential(authValue string, db *sql.DB) (int64, bool) {
 	name := pair[0]
 	password := pair[1]
 
-	result := db.QueryRow(`
+	result := db.QueryRowContext(ctx, `
 		SELECT
 		    password_hash,
 		    id
@@ -44,23 +49,49 @@ func searchCredential(authValue string, db *sql.DB) (int64, bool) {
 		return 0, false
 	}
 
-	if password != storedPasswordHash {
+	ok, needsRehash := models.CheckPassword(storedPasswordHash, password)
+	if !ok {
 		return 0, false
 	}
 
+	if needsRehash {
+		upgradePasswordHash(ctx, id, password, db)
+	}
+
 	return id, true
 }
 
+// upgradePasswordHash replaces a legacy plaintext password with its bcrypt hash.
+func upgradePasswordHash(ctx context.Context, userID int64, password string, db *sql.DB) {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, hash, userID)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// abortUnauthorized stops the handler chain with a 401 in the common error format.
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error": &routes.APIError{Code: routes.CodeUnauthorized, Message: message},
+	})
+}
+
 // createBasicAuthMiddleware returns a Basic HTTP Authorization middleware.
 func createBasicAuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//log.Println(c.Request.Header.Get("Authorization"))
 
-		userID, found := searchCredential(c.Request.Header.Get("Authorization"), db)
+		userID, found := searchCredential(c.Request.Context(), c.Request.Header.Get("Authorization"), db)
 		if !found {
 			// Credentials doesn't match, we return 401 and abort handlers chain.
 			c.Header("WWW-Authenticate", "Basic realm=Authorization Required")
-			c.AbortWithStatus(http.StatusUnauthorized)
+			abortUnauthorized(c, "invalid user name or password")
 			return
 		}
 
@@ -69,3 +100,33 @@ func createBasicAuthMiddleware(db *sql.DB) gin.HandlerFunc {
 		c.Set(gin.AuthUserKey, userID)
 	}
 }
+
+// createTokenAuthMiddleware returns a middleware that authenticates requests by a Bearer access token
+// without touching the database. Tokens of users that deleted their account are refused by the issuer.
+// When basicAuth is not nil, requests carrying Basic credentials are passed to it instead, which keeps
+// old clients working during the migration period.
+func createTokenAuthMiddleware(issuer *tokens.Issuer, basicAuth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := strings.SplitN(c.Request.Header.Get("Authorization"), " ", 2)
+
+		if len(auth) == 2 && auth[0] == "Basic" && basicAuth != nil {
+			basicAuth(c)
+			return
+		}
+
+		if len(auth) != 2 || auth[0] != "Bearer" {
+			c.Header("WWW-Authenticate", "Bearer realm=Authorization Required")
+			abortUnauthorized(c, "missing Bearer access token")
+			return
+		}
+
+		userID, err := issuer.Verify(auth[1])
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			abortUnauthorized(c, "invalid or expired access token")
+			return
+		}
+
+		c.Set(gin.AuthUserKey, userID)
+	}
+}
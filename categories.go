@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"coursify-api/models"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+const categoriesUsage = "usage: coursify-api categories list | add <slug> <name> [parent-slug] | remove <slug>"
+
+// runCategories implements the categories subcommand. Categories are curated by the operators,
+// so they are edited here rather than through the API.
+func runCategories(db *sql.DB, args []string) {
+	if len(args) == 0 {
+		log.Fatal(categoriesUsage)
+	}
+
+	model := models.NewCategoryModel(db)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		list, err := model.GetList(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		slugs := make(map[int64]string, len(list))
+		for _, category := range list {
+			slugs[category.ID] = category.Slug
+
+			parent := "-"
+			if category.ParentID != nil {
+				parent = slugs[*category.ParentID]
+			}
+			fmt.Printf("%-24s %-24s %s\n", category.Slug, parent, category.Name)
+		}
+	case "add":
+		if len(args) < 3 || len(args) > 4 {
+			log.Fatal(categoriesUsage)
+		}
+
+		in := models.CategoryInput{Slug: args[1], Name: args[2]}
+		if len(args) == 4 {
+			in.ParentSlug = args[3]
+		}
+
+		if _, err := model.Create(ctx, in); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("added %s\n", in.Slug)
+	case "remove":
+		if len(args) != 2 {
+			log.Fatal(categoriesUsage)
+		}
+
+		if err := model.Delete(ctx, args[1]); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("removed %s\n", args[1])
+	default:
+		log.Fatal(categoriesUsage)
+	}
+}
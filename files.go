@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"coursify-api/models"
+	"coursify-api/storage"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const filesUsage = "usage: coursify-api files orphans | sweep"
+
+// sweepBatch is how many files or upload sessions one round of a sweep deletes.
+const sweepBatch = 100
+
+// reportLimit caps how many orphaned files and expired upload sessions a dry run lists.
+const reportLimit = 10000
+
+// fileSweeper deletes the stored files nothing references once they are older than grace, which
+// leaves time to attach a fresh upload to a course, lesson, assignment, quiz or submission. It also deletes the chunks
+// of upload sessions that expired. Files uploaded before their metadata was recorded are never touched.
+type fileSweeper struct {
+	store   storage.Storage
+	files   models.IFileCollector
+	uploads models.IUploadCollector
+	grace   time.Duration
+}
+
+// orphans calls report with the storage key and size of every blob a sweep would delete now.
+func (s fileSweeper) orphans(ctx context.Context, report func(key string, size int64)) error {
+	list, err := s.files.GetOrphans(ctx, s.grace, reportLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range list {
+		report(file.Key, file.Size)
+	}
+
+	sessions, err := s.uploads.GetExpired(ctx, reportLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		for _, chunk := range session.Chunks {
+			report(models.UploadChunkKey(session.ID, chunk.Number), chunk.Size)
+		}
+	}
+
+	return nil
+}
+
+// sweep deletes the orphaned files and the expired upload sessions, returning how many files it
+// deleted and their total size.
+func (s fileSweeper) sweep(ctx context.Context) (int, int64, error) {
+	count, size := 0, int64(0)
+
+	for {
+		list, err := s.files.GetOrphans(ctx, s.grace, sweepBatch)
+		if err != nil {
+			return count, size, err
+		}
+
+		for _, file := range list {
+			// The blob goes first, so that a failure leaves the metadata to try again with.
+			if err = s.store.Delete(ctx, file.Key); err != nil {
+				return count, size, err
+			}
+			if err = s.files.Delete(ctx, file.ID); err != nil && !errors.Is(err, models.ErrNotFound) {
+				return count, size, err
+			}
+
+			count++
+			size += file.Size
+		}
+
+		if len(list) < sweepBatch {
+			break
+		}
+	}
+
+	for {
+		sessions, err := s.uploads.GetExpired(ctx, sweepBatch)
+		if err != nil {
+			return count, size, err
+		}
+
+		for _, session := range sessions {
+			for _, chunk := range session.Chunks {
+				if err = s.store.Delete(ctx, models.UploadChunkKey(session.ID, chunk.Number)); err != nil {
+					return count, size, err
+				}
+			}
+			if err = s.uploads.Delete(ctx, session.ID); err != nil && !errors.Is(err, models.ErrNotFound) {
+				return count, size, err
+			}
+		}
+
+		if len(sessions) < sweepBatch {
+			break
+		}
+	}
+
+	return count, size, nil
+}
+
+// run sweeps every interval until the process exits.
+func (s fileSweeper) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		count, size, err := s.sweep(ctx)
+		cancel()
+
+		if err != nil {
+			log.Printf("file sweep: %v", err)
+		}
+		if count > 0 {
+			log.Printf("file sweep: deleted %d orphaned files, %d bytes", count, size)
+		}
+	}
+}
+
+// newFileSweeper builds the sweeper from the environment:
+//
+//	FILES_GC_INTERVAL  how often the API sweeps, 1h by default, 0 to only sweep through the files subcommand
+//	FILES_GC_GRACE     how old an unreferenced file must be to be deleted, 24h by default
+//
+// It returns the sweep interval along with the sweeper.
+func newFileSweeper(db *sql.DB, store storage.Storage) (fileSweeper, time.Duration) {
+	interval, grace := time.Hour, 24*time.Hour
+
+	if value := os.Getenv("FILES_GC_INTERVAL"); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < 0 {
+			log.Fatal("FILES_GC_INTERVAL must be a duration such as 30m")
+		}
+	}
+
+	if value := os.Getenv("FILES_GC_GRACE"); value != "" {
+		var err error
+		if grace, err = time.ParseDuration(value); err != nil || grace < time.Hour {
+			log.Fatal("FILES_GC_GRACE must be a duration of at least 1h")
+		}
+	}
+
+	sweeper := fileSweeper{
+		store:   store,
+		files:   models.NewFileModel(db),
+		uploads: models.NewUploadModel(db),
+		grace:   grace,
+	}
+
+	return sweeper, interval
+}
+
+// runFiles implements the files subcommand: orphans lists what a sweep would delete without
+// deleting anything, sweep deletes it.
+func runFiles(db *sql.DB, args []string) {
+	if len(args) != 1 {
+		log.Fatal(filesUsage)
+	}
+
+	store, _ := newStorage()
+	sweeper, _ := newFileSweeper(db, store)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "orphans":
+		count, total := 0, int64(0)
+
+		err := sweeper.orphans(ctx, func(key string, size int64) {
+			fmt.Printf("%-60s %12d\n", key, size)
+			count++
+			total += size
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%d files, %d bytes would be deleted\n", count, total)
+	case "sweep":
+		count, size, err := sweeper.sweep(ctx)
+		fmt.Printf("deleted %d files, %d bytes\n", count, size)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal(filesUsage)
+	}
+}
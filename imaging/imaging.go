@@ -0,0 +1,179 @@
+// Package imaging validates uploaded images, strips their metadata and renders thumbnails.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxPixels caps the dimensions of an image that is decoded, so that a small file can't claim a huge
+// canvas and exhaust the memory.
+const MaxPixels = 40 << 20
+
+const jpegQuality = 90
+
+// Content types of the accepted images.
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+)
+
+var (
+	ErrUnsupported = errors.New("imaging: unsupported image type")
+	ErrTooLarge    = fmt.Errorf("imaging: the image has more than %d pixels", MaxPixels)
+)
+
+// Image is an uploaded image with its metadata stripped.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+
+	decoded image.Image
+}
+
+// Thumbnail is a downscaled rendition of an image that fits in a Size by Size square.
+type Thumbnail struct {
+	Size        int
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+func decodeConfig(data []byte, contentType string) (image.Config, error) {
+	r := bytes.NewReader(data)
+
+	switch contentType {
+	case JPEG:
+		return jpeg.DecodeConfig(r)
+	case PNG:
+		return png.DecodeConfig(r)
+	case GIF:
+		return gif.DecodeConfig(r)
+	case WebP:
+		return webp.DecodeConfig(r)
+	default:
+		return image.Config{}, ErrUnsupported
+	}
+}
+
+func decode(data []byte, contentType string) (image.Image, error) {
+	r := bytes.NewReader(data)
+
+	switch contentType {
+	case JPEG:
+		return jpeg.Decode(r)
+	case PNG:
+		return png.Decode(r)
+	case GIF:
+		// Thumbnails of animations show their first frame.
+		return gif.Decode(r)
+	case WebP:
+		return webp.Decode(r)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Normalize decodes data of the given content type and returns it without metadata. JPEG images are
+// turned upright as their EXIF orientation says and re-encoded, PNG images are re-encoded, WebP images
+// lose their EXIF and XMP chunks and GIF images, which carry no such metadata, are kept as they are.
+func Normalize(data []byte, contentType string) (Image, error) {
+	config, err := decodeConfig(data, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	decoded, err := decode(data, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	img := Image{ContentType: contentType, decoded: decoded}
+
+	switch contentType {
+	case JPEG:
+		img.decoded = orient(decoded, jpegOrientation(data))
+
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, img.decoded, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+		img.Data = buf.Bytes()
+	case PNG:
+		var buf bytes.Buffer
+		if err = png.Encode(&buf, decoded); err != nil {
+			return Image{}, err
+		}
+		img.Data = buf.Bytes()
+	case WebP:
+		if img.Data, err = stripWebP(data); err != nil {
+			return Image{}, err
+		}
+	default:
+		img.Data = data
+	}
+
+	bounds := img.decoded.Bounds()
+	img.Width, img.Height = bounds.Dx(), bounds.Dy()
+
+	return img, nil
+}
+
+// Thumbnail renders the image to fit in a size by size square. Images that already fit are not
+// upscaled. Thumbnails of opaque images are JPEG, the others PNG so that transparency is kept.
+func (img Image) Thumbnail(size int) (Thumbnail, error) {
+	src := img.decoded
+	bounds := src.Bounds()
+
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, height*size/width
+		} else {
+			width, height = width*size/height, size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	thumb := Thumbnail{Size: size, Width: width, Height: height}
+
+	var buf bytes.Buffer
+	var err error
+	if dst.Opaque() {
+		thumb.ContentType = JPEG
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		thumb.ContentType = PNG
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return Thumbnail{}, err
+	}
+	thumb.Data = buf.Bytes()
+
+	return thumb, nil
+}
@@ -0,0 +1,60 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedWebP = errors.New("imaging: malformed WebP container")
+
+// Flags of the VP8X chunk announcing metadata chunks.
+const (
+	vp8xEXIF = 0x08
+	vp8xXMP  = 0x04
+)
+
+// stripWebP removes the EXIF and XMP chunks from a WebP RIFF container. There is no WebP encoder to
+// re-encode with, so the image data itself is left untouched.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformedWebP
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformedWebP
+		}
+
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // chunks are padded to an even size
+		if size < 0 || end > len(data) {
+			if end-1 == len(data) && size%2 == 1 {
+				end = len(data) // tolerate a missing pad byte on the last chunk
+			} else {
+				return nil, errMalformedWebP
+			}
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, data[i:end]...)
+			if size > 0 {
+				out[start+8] &^= vp8xEXIF | vp8xXMP
+			}
+		default:
+			out = append(out, data[i:end]...)
+		}
+
+		i = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	return out, nil
+}
@@ -3,12 +3,14 @@ package main
 import (
 	"coursify-api/models"
 	"coursify-api/routes"
+	"coursify-api/tokens"
 	"database/sql"
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
 	"log"
-	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func getDataSourceName(user string, pass string, dbname string) string {
@@ -27,40 +29,167 @@ func main() {
 		}
 	}()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
+
+	requireMigrated(db)
+
+	if len(os.Args) > 1 && os.Args[1] == "categories" {
+		runCategories(db, os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "files" {
+		runFiles(db, os.Args[2:])
+		return
+	}
+
 	r := gin.Default()
+	r.Use(routes.RequestTimeout(10 * time.Second))
 
-	authMiddleware := createBasicAuthMiddleware(db)
+	secret := os.Getenv("AUTH_SECRET")
+	if secret == "" {
+		log.Fatal("AUTH_SECRET is not set")
+	}
+	issuer := tokens.NewIssuer(secret, 15*time.Minute, 30*24*time.Hour)
+
+	basicAuthMiddleware := createBasicAuthMiddleware(db)
+
+	// AUTH_BASIC_ENABLED keeps Basic credentials accepted on every endpoint while clients move to tokens.
+	var basicFallback gin.HandlerFunc
+	if os.Getenv("AUTH_BASIC_ENABLED") == "true" {
+		basicFallback = basicAuthMiddleware
+	}
+	authMiddleware := createTokenAuthMiddleware(issuer, basicFallback)
 
 	userModel := models.NewUserModel(db)
 	courseModel := models.NewCourseModel(db)
+	sessionModel := models.NewSessionModel(db)
+	lessonModel := models.NewLessonModel(db)
+	componentModel := models.NewComponentModel(db)
+	progressModel := models.NewProgressModel(db)
+	quizModel := models.NewQuizModel(db)
+	assignmentModel := models.NewAssignmentModel(db)
+	invitationModel := models.NewInvitationModel(db)
+	categoryModel := models.NewCategoryModel(db)
+	fileModel := models.NewFileModel(db)
+	uploadModel := models.NewUploadModel(db)
 
 	coursesGroup := r.Group("/courses", authMiddleware)
 	usersGroup := r.Group("/users", authMiddleware)
 
-	//lessonModel := models.NewLessonModel(db)
-	//lessonsGroup := r.Group("/lessons", authMiddleware)
-	//lessonsGroup.GET("/", routes.ListLessons(lessonModel))
-	//lessonsGroup.POST("/", routes.CreateLesson(lessonModel))
-	//lessonsGroup.GET("/:id", routes.GetLesson(lessonModel))
-	//lessonsGroup.PUT("/:id", routes.UpdateLesson(lessonModel))
-	//lessonsGroup.DELETE("/:id", routes.DeleteLesson(lessonModel))
-
 	coursesGroup.GET("/", routes.ListCourses(courseModel))
 	coursesGroup.POST("/", routes.CreateCourse(courseModel))
 	coursesGroup.POST("/:id/enter/", routes.EnterCourse(courseModel))
 	coursesGroup.POST("/:id/leave/", routes.LeaveCourse(courseModel))
-	coursesGroup.GET("/:id", routes.GetCourse(courseModel))
-	coursesGroup.DELETE("/:id", routes.DeleteCourse(courseModel))
-	coursesGroup.PUT("/:id", routes.UpdateCourse(courseModel))
+	coursesGroup.GET("/:id", routes.RequireCoursePermission(courseModel, models.PermissionViewCourse), routes.GetCourse(courseModel, progressModel))
+	coursesGroup.DELETE("/:id", routes.RequireCoursePermission(courseModel, models.PermissionDeleteCourse), routes.DeleteCourse(courseModel))
+	coursesGroup.PUT("/:id", routes.RequireCoursePermission(courseModel, models.PermissionEditCourse), routes.UpdateCourse(courseModel))
+	coursesGroup.POST("/:id/status/", routes.RequireCoursePermission(courseModel, models.PermissionChangeStatus), routes.SetCourseStatus(courseModel))
+
+	viewWork := routes.RequireCoursePermission(courseModel, models.PermissionViewWork)
+	enrollments := routes.RequireCoursePermission(courseModel, models.PermissionEnrollments)
+
+	coursesGroup.GET("/:id/enrollments/", viewWork, routes.ListEnrollments(courseModel))
+	coursesGroup.POST("/:id/enrollments/", enrollments, routes.InviteStudent(courseModel))
+	coursesGroup.POST("/:id/enrollments/:userId/approve/", enrollments, routes.ApproveEnrollment(courseModel))
+	coursesGroup.POST("/:id/enrollments/:userId/reject/", enrollments, routes.RejectEnrollment(courseModel))
+
+	coursesGroup.GET("/:id/students/", viewWork, routes.ListStudents(courseModel))
+	coursesGroup.GET("/:id/students/export/", viewWork, routes.ExportStudents(courseModel))
+	coursesGroup.POST("/:id/students/", enrollments, routes.EnrollStudents(courseModel))
+	coursesGroup.DELETE("/:id/students/:userId", enrollments, routes.RemoveStudent(courseModel))
+
+	manageMentors := routes.RequireCoursePermission(courseModel, models.PermissionManageMentors)
+
+	coursesGroup.DELETE("/:id/mentors/:userId", manageMentors, routes.RemoveMentor(courseModel))
+	coursesGroup.GET("/:id/invitations/", manageMentors, routes.ListCourseInvitations(invitationModel))
+	coursesGroup.POST("/:id/invitations/", manageMentors, routes.InviteMentor(invitationModel))
+	coursesGroup.DELETE("/:id/invitations/:invitationId", manageMentors, routes.RevokeInvitation(invitationModel))
+
+	viewLessons := routes.RequireCoursePermission(courseModel, models.PermissionViewLessons)
+	editLessons := routes.RequireCoursePermission(courseModel, models.PermissionEditLessons)
+	participate := routes.RequireCoursePermission(courseModel, models.PermissionParticipate)
+
+	coursesGroup.GET("/:id/lessons/", viewLessons, routes.ListLessons(lessonModel))
+	coursesGroup.POST("/:id/lessons/", editLessons, routes.CreateLesson(lessonModel))
+	coursesGroup.POST("/:id/reorder-lessons/", editLessons, routes.ReorderLessons(lessonModel))
+	coursesGroup.GET("/:id/lessons/:lessonId", viewLessons, routes.GetLesson(lessonModel))
+	coursesGroup.PUT("/:id/lessons/:lessonId", editLessons, routes.UpdateLesson(lessonModel))
+	coursesGroup.DELETE("/:id/lessons/:lessonId", editLessons, routes.DeleteLesson(lessonModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/start/", participate, routes.StartLesson(lessonModel, progressModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/complete/", participate, routes.CompleteLesson(lessonModel, progressModel))
+
+	coursesGroup.GET("/:id/lessons/:lessonId/components/", viewLessons, routes.ListComponents(lessonModel, componentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/components/", editLessons, routes.CreateComponent(lessonModel, componentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/reorder-components/", editLessons, routes.ReorderComponents(lessonModel, componentModel))
+	coursesGroup.GET("/:id/lessons/:lessonId/components/:componentId", viewLessons, routes.GetComponent(lessonModel, componentModel))
+	coursesGroup.PUT("/:id/lessons/:lessonId/components/:componentId", editLessons, routes.UpdateComponent(lessonModel, componentModel))
+	coursesGroup.DELETE("/:id/lessons/:lessonId/components/:componentId", editLessons, routes.DeleteComponent(lessonModel, componentModel))
+
+	coursesGroup.GET("/:id/lessons/:lessonId/quizzes/", viewLessons, routes.ListQuizzes(lessonModel, quizModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/quizzes/", editLessons, routes.CreateQuiz(lessonModel, quizModel))
+	coursesGroup.GET("/:id/lessons/:lessonId/quizzes/:quizId", viewLessons, routes.GetQuiz(lessonModel, quizModel))
+	coursesGroup.PUT("/:id/lessons/:lessonId/quizzes/:quizId", editLessons, routes.UpdateQuiz(lessonModel, quizModel))
+	coursesGroup.DELETE("/:id/lessons/:lessonId/quizzes/:quizId", editLessons, routes.DeleteQuiz(lessonModel, quizModel))
+	coursesGroup.GET("/:id/lessons/:lessonId/quizzes/:quizId/attempts/", viewLessons, routes.ListAttempts(lessonModel, quizModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/quizzes/:quizId/attempts/", participate, routes.SubmitAttempt(lessonModel, quizModel, progressModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/quizzes/:quizId/attempts/:attemptId/review/", editLessons, routes.ReviewAttempt(lessonModel, quizModel, progressModel))
+
+	coursesGroup.GET("/:id/submissions/", viewWork, routes.ListCourseSubmissions(assignmentModel))
+	coursesGroup.GET("/:id/lessons/:lessonId/assignments/", viewLessons, routes.ListAssignments(lessonModel, assignmentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/assignments/", editLessons, routes.CreateAssignment(lessonModel, assignmentModel))
+	coursesGroup.GET("/:id/lessons/:lessonId/assignments/:assignmentId", viewLessons, routes.GetAssignment(lessonModel, assignmentModel))
+	coursesGroup.PUT("/:id/lessons/:lessonId/assignments/:assignmentId", editLessons, routes.UpdateAssignment(lessonModel, assignmentModel))
+	coursesGroup.DELETE("/:id/lessons/:lessonId/assignments/:assignmentId", editLessons, routes.DeleteAssignment(lessonModel, assignmentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/assignments/:assignmentId/submissions/", participate, routes.SubmitAssignment(lessonModel, assignmentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/assignments/:assignmentId/submissions/:submissionId/review/", editLessons, routes.ReviewSubmission(lessonModel, assignmentModel))
+	coursesGroup.POST("/:id/lessons/:lessonId/assignments/:assignmentId/submissions/:submissionId/return/", editLessons, routes.ReturnSubmission(lessonModel, assignmentModel))
 
 	usersGroup.GET("/self/", routes.GetSelf(userModel))
+	usersGroup.PATCH("/self/", routes.UpdateSelf(userModel))
+	usersGroup.DELETE("/self/", routes.DeleteSelf(userModel, issuer))
+	usersGroup.POST("/self/password/", routes.ChangePassword(userModel))
+	usersGroup.GET("/self/submissions/", routes.ListSelfSubmissions(assignmentModel))
+	usersGroup.GET("/self/invitations/", routes.ListSelfInvitations(invitationModel))
+	usersGroup.POST("/self/invitations/:invitationId/accept/", routes.AcceptInvitation(invitationModel))
+	usersGroup.POST("/self/invitations/:invitationId/decline/", routes.DeclineInvitation(invitationModel))
 	usersGroup.GET("/", routes.ListUsers(userModel))
 
+	r.GET("/categories/", authMiddleware, routes.ListCategories(categoryModel))
+
 	r.POST("/register/", routes.RegisterUser(userModel))
-	r.GET("/login/", authMiddleware, routes.LogInUser(userModel))
+	r.GET("/login/", basicAuthMiddleware, routes.LogInUser(sessionModel, issuer))
+	r.POST("/logout/", authMiddleware, routes.LogOutUser(sessionModel))
+	r.POST("/token/refresh/", routes.RefreshToken(sessionModel, issuer))
+
+	store, local := newStorage()
+
+	sweeper, sweepInterval := newFileSweeper(db, store)
+	if sweepInterval > 0 {
+		go sweeper.run(sweepInterval)
+	}
+
+	r.POST("/fs/images/", authMiddleware, routes.PostImageFile(store, fileModel, newImageOptions()))
+	r.POST("/fs/files/", authMiddleware, routes.PostFile(store, fileModel))
+	if local != nil {
+		for _, kind := range []string{models.FileKindImage, models.FileKindFile} {
+			serve := routes.ServeStoredFiles(filepath.Join(local.Dir, kind), kind, fileModel, courseModel, authMiddleware)
+			r.GET("/fs/"+kind+"/*filepath", serve)
+			r.HEAD("/fs/"+kind+"/*filepath", serve)
+		}
+	}
+
+	// Chunks and the assembly of large files take longer than the other requests.
+	uploadsGroup := r.Group("/uploads", authMiddleware, routes.RequestTimeout(10*time.Minute))
 
-	r.POST("/fs/images/", routes.PostImageFile("file_storage"))
-	r.StaticFS("/fs/images/", http.Dir("file_storage/images"))
+	uploadsGroup.POST("/", routes.CreateUpload(uploadModel))
+	uploadsGroup.GET("/:uploadId", routes.GetUpload(uploadModel))
+	uploadsGroup.DELETE("/:uploadId", routes.CancelUpload(store, uploadModel))
+	uploadsGroup.PUT("/:uploadId/chunks/:number", routes.PutUploadChunk(store, uploadModel))
+	uploadsGroup.POST("/:uploadId/complete/", routes.CompleteUpload(store, uploadModel, fileModel))
 
 	err = r.Run()
 	if err != nil {
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"coursify-api/migrations"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = "usage: coursify-api migrate up | down [steps] | status | baseline [version]"
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(db *sql.DB, args []string) {
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := runner.Up(ctx)
+		for _, m := range done {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("nothing to apply")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+
+		done, err := runner.Down(ctx, steps)
+		for _, m := range done {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "baseline":
+		// Databases created before migrations were tracked already have the initial schema.
+		version := 1
+		if len(args) > 1 {
+			if version, err = strconv.Atoi(args[1]); err != nil || version < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+
+		done, err := runner.Baseline(ctx, version)
+		for _, m := range done {
+			fmt.Printf("marked %04d_%s as applied\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+// requireMigrated stops the server when the database schema is behind the code. It only reads the
+// schema_migrations table and never creates it.
+func requireMigrated(db *sql.DB) {
+	runner, err := migrations.NewRunner(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pending, err := runner.Pending(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(pending) > 0 {
+		log.Fatalf(
+			"%d database migrations are pending, run `coursify-api migrate up` first "+
+				"(after `coursify-api migrate baseline` if the tables were created before migrations were tracked)",
+			len(pending),
+		)
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE lessons;
+DROP TABLE mentors;
+DROP TABLE students;
+DROP TABLE courses;
+DROP TABLE users;
@@ -0,0 +1,55 @@
+CREATE TABLE users (
+    id            INT          NOT NULL AUTO_INCREMENT,
+    user_name     VARCHAR(64)  NOT NULL,
+    full_name     VARCHAR(255) NOT NULL DEFAULT '',
+    avatar        VARCHAR(512) NOT NULL DEFAULT '',
+    about         TEXT         NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    date_created  DATETIME     NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY users_user_name (user_name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE courses (
+    id          INT          NOT NULL AUTO_INCREMENT,
+    title       VARCHAR(255) NOT NULL,
+    description TEXT         NOT NULL,
+    owner_id    INT          NOT NULL,
+    avatar      VARCHAR(512) NOT NULL DEFAULT '',
+    PRIMARY KEY (id),
+    KEY courses_owner_id (owner_id),
+    CONSTRAINT courses_owner_fk FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE students (
+    course_id INT    NOT NULL,
+    user_id   INT    NOT NULL,
+    progress  DOUBLE NOT NULL DEFAULT 0,
+    PRIMARY KEY (course_id, user_id),
+    KEY students_user_id (user_id),
+    CONSTRAINT students_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT students_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE mentors (
+    course_id INT         NOT NULL,
+    user_id   INT         NOT NULL,
+    role      VARCHAR(32) NOT NULL,
+    PRIMARY KEY (course_id, user_id),
+    KEY mentors_user_id (user_id),
+    CONSTRAINT mentors_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT mentors_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE lessons (
+    id          INT          NOT NULL AUTO_INCREMENT,
+    course_id   INT          NOT NULL,
+    number      INT          NOT NULL,
+    title       VARCHAR(255) NOT NULL,
+    theme       VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT         NOT NULL,
+    header_ava  MEDIUMBLOB,
+    PRIMARY KEY (id),
+    UNIQUE KEY lessons_course_number (course_id, number),
+    CONSTRAINT lessons_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,11 @@
+CREATE TABLE refresh_tokens (
+    id         BIGINT   NOT NULL AUTO_INCREMENT,
+    user_id    INT      NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked    BOOLEAN  NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (id),
+    UNIQUE KEY refresh_tokens_token_hash (token_hash),
+    KEY refresh_tokens_user_id (user_id),
+    CONSTRAINT refresh_tokens_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
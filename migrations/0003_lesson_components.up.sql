@@ -0,0 +1,10 @@
+CREATE TABLE lesson_components (
+    id        INT         NOT NULL AUTO_INCREMENT,
+    lesson_id INT         NOT NULL,
+    number    INT         NOT NULL,
+    type      VARCHAR(16) NOT NULL,
+    content   JSON        NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY lesson_components_lesson_number (lesson_id, number),
+    CONSTRAINT lesson_components_lesson_fk FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1 @@
+DROP TABLE lesson_progress;
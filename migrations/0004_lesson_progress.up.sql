@@ -0,0 +1,10 @@
+CREATE TABLE lesson_progress (
+    user_id      INT      NOT NULL,
+    lesson_id    INT      NOT NULL,
+    started_at   DATETIME NOT NULL,
+    completed_at DATETIME NULL,
+    PRIMARY KEY (user_id, lesson_id),
+    KEY lesson_progress_lesson_id (lesson_id),
+    CONSTRAINT lesson_progress_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT lesson_progress_lesson_fk FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+DROP TABLE quiz_attempts;
+DROP TABLE quiz_questions;
+DROP TABLE quizzes;
@@ -0,0 +1,41 @@
+CREATE TABLE quizzes (
+    id                     INT          NOT NULL AUTO_INCREMENT,
+    lesson_id              INT          NOT NULL,
+    title                  VARCHAR(255) NOT NULL,
+    pass_score             DOUBLE       NOT NULL DEFAULT 0,
+    counts_toward_progress BOOLEAN      NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (id),
+    KEY quizzes_lesson_id (lesson_id),
+    CONSTRAINT quizzes_lesson_fk FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE quiz_questions (
+    id      INT         NOT NULL AUTO_INCREMENT,
+    quiz_id INT         NOT NULL,
+    number  INT         NOT NULL,
+    type    VARCHAR(16) NOT NULL,
+    text    TEXT        NOT NULL,
+    options JSON        NOT NULL,
+    points  INT         NOT NULL DEFAULT 1,
+    answer  JSON        NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY quiz_questions_quiz_number (quiz_id, number),
+    CONSTRAINT quiz_questions_quiz_fk FOREIGN KEY (quiz_id) REFERENCES quizzes (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE quiz_attempts (
+    id             INT      NOT NULL AUTO_INCREMENT,
+    quiz_id        INT      NOT NULL,
+    user_id        INT      NOT NULL,
+    score          INT      NOT NULL,
+    max_score      INT      NOT NULL,
+    passed         BOOLEAN  NOT NULL,
+    pending_review BOOLEAN  NOT NULL,
+    responses      JSON     NOT NULL,
+    date_created   DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY quiz_attempts_quiz_user (quiz_id, user_id),
+    KEY quiz_attempts_user_id (user_id),
+    CONSTRAINT quiz_attempts_quiz_fk FOREIGN KEY (quiz_id) REFERENCES quizzes (id) ON DELETE CASCADE,
+    CONSTRAINT quiz_attempts_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,30 @@
+CREATE TABLE assignments (
+    id          INT          NOT NULL AUTO_INCREMENT,
+    lesson_id   INT          NOT NULL,
+    title       VARCHAR(255) NOT NULL,
+    description TEXT         NOT NULL,
+    due_date    DATETIME     NOT NULL,
+    max_score   INT          NOT NULL,
+    PRIMARY KEY (id),
+    KEY assignments_lesson_id (lesson_id),
+    CONSTRAINT assignments_lesson_fk FOREIGN KEY (lesson_id) REFERENCES lessons (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE submissions (
+    id             INT           NOT NULL AUTO_INCREMENT,
+    assignment_id  INT           NOT NULL,
+    user_id        INT           NOT NULL,
+    file_url       VARCHAR(1024) NOT NULL,
+    comment        TEXT          NOT NULL,
+    status         VARCHAR(16)   NOT NULL,
+    score          INT           NULL,
+    feedback       VARCHAR(4096) NOT NULL DEFAULT '',
+    late           BOOLEAN       NOT NULL DEFAULT FALSE,
+    date_submitted DATETIME      NOT NULL,
+    date_reviewed  DATETIME      NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY submissions_assignment_user (assignment_id, user_id),
+    KEY submissions_user_status (user_id, status),
+    CONSTRAINT submissions_assignment_fk FOREIGN KEY (assignment_id) REFERENCES assignments (id) ON DELETE CASCADE,
+    CONSTRAINT submissions_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1 @@
+DROP TABLE mentor_invitations;
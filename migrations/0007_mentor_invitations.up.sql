@@ -0,0 +1,17 @@
+CREATE TABLE mentor_invitations (
+    id           INT         NOT NULL AUTO_INCREMENT,
+    course_id    INT         NOT NULL,
+    user_id      INT         NOT NULL,
+    invited_by   INT         NOT NULL,
+    role         VARCHAR(32) NOT NULL,
+    status       VARCHAR(16) NOT NULL,
+    date_created DATETIME    NOT NULL,
+    expires_at   DATETIME    NOT NULL,
+    responded_at DATETIME    NULL,
+    PRIMARY KEY (id),
+    KEY mentor_invitations_course_id (course_id),
+    KEY mentor_invitations_user_status (user_id, status),
+    CONSTRAINT mentor_invitations_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT mentor_invitations_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT mentor_invitations_invited_by_fk FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+DROP INDEX users_name_fulltext ON users;
+DROP INDEX courses_search_fulltext ON courses;
+DROP INDEX courses_title_fulltext ON courses;
@@ -0,0 +1,3 @@
+CREATE FULLTEXT INDEX courses_title_fulltext ON courses (title);
+CREATE FULLTEXT INDEX courses_search_fulltext ON courses (title, description);
+CREATE FULLTEXT INDEX users_name_fulltext ON users (full_name, user_name);
@@ -0,0 +1,5 @@
+DROP INDEX users_user_name_id ON users;
+DROP INDEX courses_students_count_id ON courses;
+DROP INDEX courses_title_id ON courses;
+
+ALTER TABLE courses DROP COLUMN students_count;
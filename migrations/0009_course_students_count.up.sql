@@ -0,0 +1,7 @@
+ALTER TABLE courses ADD COLUMN students_count INT NOT NULL DEFAULT 0;
+
+UPDATE courses c SET students_count = (SELECT COUNT(*) FROM students s WHERE s.course_id = c.id);
+
+CREATE INDEX courses_title_id ON courses (title, id);
+CREATE INDEX courses_students_count_id ON courses (students_count, id);
+CREATE INDEX users_user_name_id ON users (user_name, id);
@@ -0,0 +1,9 @@
+DROP TABLE course_tags;
+DROP TABLE tags;
+
+ALTER TABLE courses
+    DROP FOREIGN KEY courses_category_fk,
+    DROP KEY courses_category_id,
+    DROP COLUMN category_id;
+
+DROP TABLE categories;
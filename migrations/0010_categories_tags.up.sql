@@ -0,0 +1,31 @@
+CREATE TABLE categories (
+    id        INT          NOT NULL AUTO_INCREMENT,
+    parent_id INT          NULL,
+    slug      VARCHAR(64)  NOT NULL,
+    name      VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY categories_slug (slug),
+    KEY categories_parent_id (parent_id),
+    CONSTRAINT categories_parent_fk FOREIGN KEY (parent_id) REFERENCES categories (id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE courses
+    ADD COLUMN category_id INT NULL,
+    ADD KEY courses_category_id (category_id),
+    ADD CONSTRAINT courses_category_fk FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL;
+
+CREATE TABLE tags (
+    id   INT         NOT NULL AUTO_INCREMENT,
+    name VARCHAR(64) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY tags_name (name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE course_tags (
+    course_id INT NOT NULL,
+    tag_id    INT NOT NULL,
+    PRIMARY KEY (course_id, tag_id),
+    KEY course_tags_tag_id (tag_id),
+    CONSTRAINT course_tags_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT course_tags_tag_fk FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE courses
+    DROP KEY courses_status,
+    DROP COLUMN status;
@@ -0,0 +1,5 @@
+ALTER TABLE courses
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published',
+    ADD KEY courses_status (status);
+
+ALTER TABLE courses ALTER COLUMN status SET DEFAULT 'draft';
@@ -0,0 +1,8 @@
+DROP TABLE enrollments;
+
+ALTER TABLE courses
+    DROP COLUMN waitlist,
+    DROP COLUMN enrollment_closes,
+    DROP COLUMN enrollment_opens,
+    DROP COLUMN capacity,
+    DROP COLUMN enrollment_mode;
@@ -0,0 +1,22 @@
+ALTER TABLE courses
+    ADD COLUMN enrollment_mode   VARCHAR(16) NOT NULL DEFAULT 'open',
+    ADD COLUMN capacity          INT         NULL,
+    ADD COLUMN enrollment_opens  DATETIME    NULL,
+    ADD COLUMN enrollment_closes DATETIME    NULL,
+    ADD COLUMN waitlist          BOOLEAN     NOT NULL DEFAULT FALSE;
+
+CREATE TABLE enrollments (
+    course_id      INT           NOT NULL,
+    user_id        INT           NOT NULL,
+    status         VARCHAR(16)   NOT NULL,
+    reason         VARCHAR(1024) NOT NULL DEFAULT '',
+    date_requested DATETIME      NOT NULL,
+    date_decided   DATETIME      NULL,
+    decided_by     INT           NULL,
+    PRIMARY KEY (course_id, user_id),
+    KEY enrollments_course_status (course_id, status, date_requested),
+    KEY enrollments_user_id (user_id),
+    CONSTRAINT enrollments_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT enrollments_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT enrollments_decided_by_fk FOREIGN KEY (decided_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
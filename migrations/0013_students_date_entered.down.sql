@@ -0,0 +1,3 @@
+DROP INDEX students_course_date_entered ON students;
+
+ALTER TABLE students DROP COLUMN date_entered;
@@ -0,0 +1,11 @@
+ALTER TABLE students ADD COLUMN date_entered DATETIME NULL;
+
+UPDATE students s SET date_entered = COALESCE((
+    SELECT MIN(lp.started_at)
+    FROM lesson_progress lp JOIN lessons l ON l.id = lp.lesson_id
+    WHERE l.course_id = s.course_id AND lp.user_id = s.user_id
+), NOW());
+
+ALTER TABLE students MODIFY COLUMN date_entered DATETIME NOT NULL;
+
+CREATE INDEX students_course_date_entered ON students (course_id, date_entered, user_id);
@@ -0,0 +1,16 @@
+CREATE TABLE files (
+    id            INT           NOT NULL AUTO_INCREMENT,
+    storage_key   VARCHAR(255)  NOT NULL,
+    kind          VARCHAR(16)   NOT NULL,
+    owner_id      INT           NULL,
+    original_name VARCHAR(255)  NOT NULL DEFAULT '',
+    content_type  VARCHAR(127)  NOT NULL,
+    size          BIGINT        NOT NULL,
+    checksum      CHAR(64)      NOT NULL,
+    url           VARCHAR(1024) NOT NULL,
+    date_created  DATETIME      NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY files_storage_key (storage_key),
+    KEY files_owner_id (owner_id),
+    CONSTRAINT files_owner_fk FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,6 @@
+ALTER TABLE files
+    DROP FOREIGN KEY files_parent_fk,
+    DROP KEY files_parent_id,
+    DROP COLUMN parent_id,
+    DROP COLUMN width,
+    DROP COLUMN height;
@@ -0,0 +1,6 @@
+ALTER TABLE files
+    ADD COLUMN parent_id INT NULL,
+    ADD COLUMN width     INT NULL,
+    ADD COLUMN height    INT NULL,
+    ADD KEY files_parent_id (parent_id),
+    ADD CONSTRAINT files_parent_fk FOREIGN KEY (parent_id) REFERENCES files (id) ON DELETE CASCADE;
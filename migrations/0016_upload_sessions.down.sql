@@ -0,0 +1,7 @@
+ALTER TABLE submissions
+    DROP FOREIGN KEY submissions_file_fk,
+    DROP KEY submissions_file_id,
+    DROP COLUMN file_id;
+
+DROP TABLE upload_chunks;
+DROP TABLE upload_sessions;
@@ -0,0 +1,33 @@
+CREATE TABLE upload_sessions (
+    id           INT          NOT NULL AUTO_INCREMENT,
+    owner_id     INT          NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    content_type VARCHAR(127) NOT NULL,
+    size         BIGINT       NOT NULL,
+    chunk_size   BIGINT       NOT NULL,
+    checksum     CHAR(64)     NOT NULL,
+    status       VARCHAR(16)  NOT NULL,
+    file_id      INT          NULL,
+    date_created DATETIME     NOT NULL,
+    expires_at   DATETIME     NOT NULL,
+    PRIMARY KEY (id),
+    KEY upload_sessions_owner_id (owner_id),
+    KEY upload_sessions_expires_at (expires_at),
+    CONSTRAINT upload_sessions_owner_fk FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT upload_sessions_file_fk FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE SET NULL
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+CREATE TABLE upload_chunks (
+    session_id    INT      NOT NULL,
+    number        INT      NOT NULL,
+    size          BIGINT   NOT NULL,
+    checksum      CHAR(64) NOT NULL,
+    date_received DATETIME NOT NULL,
+    PRIMARY KEY (session_id, number),
+    CONSTRAINT upload_chunks_session_fk FOREIGN KEY (session_id) REFERENCES upload_sessions (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+ALTER TABLE submissions
+    ADD COLUMN file_id INT NULL,
+    ADD KEY submissions_file_id (file_id),
+    ADD CONSTRAINT submissions_file_fk FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE SET NULL;
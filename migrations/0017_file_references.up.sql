@@ -0,0 +1,32 @@
+CREATE TABLE file_references (
+    id            INT NOT NULL AUTO_INCREMENT,
+    file_id       INT NOT NULL,
+    course_id     INT NULL,
+    user_id       INT NULL,
+    component_id  INT NULL,
+    submission_id INT NULL,
+    PRIMARY KEY (id),
+    KEY file_references_file_id (file_id),
+    KEY file_references_course_id (course_id),
+    KEY file_references_user_id (user_id),
+    KEY file_references_component_id (component_id),
+    KEY file_references_submission_id (submission_id),
+    CONSTRAINT file_references_file_fk FOREIGN KEY (file_id) REFERENCES files (id) ON DELETE CASCADE,
+    CONSTRAINT file_references_course_fk FOREIGN KEY (course_id) REFERENCES courses (id) ON DELETE CASCADE,
+    CONSTRAINT file_references_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT file_references_component_fk FOREIGN KEY (component_id) REFERENCES lesson_components (id) ON DELETE CASCADE,
+    CONSTRAINT file_references_submission_fk FOREIGN KEY (submission_id) REFERENCES submissions (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+INSERT INTO file_references(file_id, course_id)
+SELECT f.id, c.id FROM courses c JOIN files f ON RIGHT(c.avatar, CHAR_LENGTH(f.storage_key) + 1) = CONCAT('/', f.storage_key);
+
+INSERT INTO file_references(file_id, user_id)
+SELECT f.id, u.id FROM users u JOIN files f ON RIGHT(u.avatar, CHAR_LENGTH(f.storage_key) + 1) = CONCAT('/', f.storage_key);
+
+INSERT INTO file_references(file_id, component_id)
+SELECT f.id, lc.id FROM lesson_components lc
+JOIN files f ON LOCATE(CONCAT('/', f.storage_key), CAST(lc.content AS CHAR)) > 0;
+
+INSERT INTO file_references(file_id, submission_id)
+SELECT f.id, s.id FROM submissions s JOIN files f ON RIGHT(s.file_url, CHAR_LENGTH(f.storage_key) + 1) = CONCAT('/', f.storage_key);
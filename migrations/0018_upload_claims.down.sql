@@ -0,0 +1,3 @@
+ALTER TABLE upload_sessions
+    DROP COLUMN claim,
+    DROP COLUMN claimed_at;
@@ -0,0 +1,3 @@
+ALTER TABLE upload_sessions
+    ADD COLUMN claim      INT      NOT NULL DEFAULT 0,
+    ADD COLUMN claimed_at DATETIME NULL;
@@ -0,0 +1,9 @@
+DELETE FROM file_references WHERE assignment_id IS NOT NULL OR quiz_id IS NOT NULL;
+
+ALTER TABLE file_references
+    DROP FOREIGN KEY file_references_assignment_fk,
+    DROP FOREIGN KEY file_references_quiz_fk,
+    DROP KEY file_references_assignment_id,
+    DROP KEY file_references_quiz_id,
+    DROP COLUMN assignment_id,
+    DROP COLUMN quiz_id;
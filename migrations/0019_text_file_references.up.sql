@@ -0,0 +1,17 @@
+ALTER TABLE file_references
+    ADD COLUMN assignment_id INT NULL,
+    ADD COLUMN quiz_id       INT NULL,
+    ADD KEY file_references_assignment_id (assignment_id),
+    ADD KEY file_references_quiz_id (quiz_id),
+    ADD CONSTRAINT file_references_assignment_fk FOREIGN KEY (assignment_id) REFERENCES assignments (id) ON DELETE CASCADE,
+    ADD CONSTRAINT file_references_quiz_fk FOREIGN KEY (quiz_id) REFERENCES quizzes (id) ON DELETE CASCADE;
+
+INSERT INTO file_references(file_id, course_id)
+SELECT f.id, c.id FROM courses c JOIN files f ON LOCATE(CONCAT('/', f.storage_key), c.description) > 0;
+
+INSERT INTO file_references(file_id, assignment_id)
+SELECT f.id, a.id FROM assignments a JOIN files f ON LOCATE(CONCAT('/', f.storage_key), a.description) > 0;
+
+INSERT INTO file_references(file_id, quiz_id)
+SELECT DISTINCT f.id, q.quiz_id FROM quiz_questions q
+JOIN files f ON LOCATE(CONCAT('/', f.storage_key), CONCAT(q.text, CAST(q.options AS CHAR))) > 0;
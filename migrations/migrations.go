@@ -0,0 +1,342 @@
+// Package migrations holds the versioned SQL schema of the database and applies it.
+//
+// Every version has a NNNN_name.up.sql and a NNNN_name.down.sql file. Applied versions
+// are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the embedded migrations ordered by version.
+func Load() ([]Migration, error) {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(name, "."+direction+".sql"), "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", name, direction)
+		}
+
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// statements splits a migration file into single statements, because the driver runs one at a time.
+// Statements end at semicolons outside of quoted strings and quoted identifiers. Comments are left out.
+func statements(script string) []string {
+	result := make([]string, 0)
+	var statement strings.Builder
+	start := 0
+
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// Skip to the closing quote. Backslashes escape the next character in strings, and
+			// doubled quotes stand for the quote itself, which the loop handles as two strings.
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case c == '#' || c == '-' && strings.HasPrefix(script[i:], "-- "):
+			statement.WriteString(script[start:i])
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			start = i
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			statement.WriteString(script[start:i])
+			if end := strings.Index(script[i+2:], "*/"); end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+			start = i + 1
+		case c == ';':
+			statement.WriteString(script[start:i])
+			if text := strings.TrimSpace(statement.String()); text != "" {
+				result = append(result, text)
+			}
+			statement.Reset()
+			start = i + 1
+		}
+	}
+
+	if start < len(script) {
+		statement.WriteString(script[start:])
+	}
+	if text := strings.TrimSpace(statement.String()); text != "" {
+		result = append(result, text)
+	}
+
+	return result
+}
+
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewRunner(db *sql.DB) (*Runner, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{db, migrations}, nil
+}
+
+func (r *Runner) ensureTable(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT          NOT NULL,
+			name       VARCHAR(255) NOT NULL,
+			applied_at DATETIME     NOT NULL,
+			PRIMARY KEY (version)
+		) ENGINE = InnoDB
+	`)
+
+	return err
+}
+
+// applied returns when each applied version was applied. It only reads, so a database without the
+// schema_migrations table has nothing applied.
+func (r *Runner) applied(ctx context.Context) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	var tables int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'
+	`).Scan(&tables)
+	if err != nil || tables == 0 {
+		return applied, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and when it was applied, if it was.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		statuses[i].Migration = m
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that are not applied yet, in the order they would be applied.
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// run executes a migration script and records or removes its version. MySQL commits DDL implicitly,
+// so a failing statement leaves the earlier statements of the same file applied.
+func (r *Runner) run(ctx context.Context, m Migration, up bool) error {
+	script := m.Down
+	if up {
+		script = m.Up
+	}
+
+	for _, statement := range statements(script) {
+		if _, err := r.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+	}
+
+	var err error
+	if up {
+		_, err = r.db.ExecContext(ctx, `INSERT INTO schema_migrations(version, name, applied_at) VALUE(?, ?, NOW())`, m.Version, m.Name)
+	} else {
+		_, err = r.db.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+
+	return err
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	pending, err := r.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(pending))
+	for _, m := range pending {
+		if err = r.run(ctx, m, true); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the given number of most recently applied migrations and returns the ones it rolled back.
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, steps)
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+
+		if err = r.run(ctx, statuses[i].Migration, false); err != nil {
+			return done, err
+		}
+		done = append(done, statuses[i].Migration)
+	}
+
+	return done, nil
+}
+
+// Baseline records the migrations up to version as applied without running them, for databases whose
+// schema was created before migrations were tracked. It refuses to touch a database that already has
+// applied migrations, and returns the ones it recorded.
+func (r *Runner) Baseline(ctx context.Context, version int) ([]Migration, error) {
+	if err := r.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		return nil, fmt.Errorf("the database already has %d applied migrations", len(applied))
+	}
+
+	done := make([]Migration, 0)
+	for _, m := range r.migrations {
+		if m.Version > version {
+			break
+		}
+
+		_, err = r.db.ExecContext(ctx, `INSERT INTO schema_migrations(version, name, applied_at) VALUE(?, ?, NOW())`, m.Version, m.Name)
+		if err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+
+	return done, nil
+}
@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "terminated statements",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "trailing statement without terminator",
+			script: "DROP TABLE a;\nDROP TABLE b\n",
+			want:   []string{"DROP TABLE a", "DROP TABLE b"},
+		},
+		{
+			name:   "empty statements",
+			script: ";\n  ;DROP TABLE a;;\n",
+			want:   []string{"DROP TABLE a"},
+		},
+		{
+			name:   "semicolon in a single quoted string",
+			script: "INSERT INTO t VALUE('a;b');\nDROP TABLE a;",
+			want:   []string{"INSERT INTO t VALUE('a;b')", "DROP TABLE a"},
+		},
+		{
+			name:   "semicolon in a double quoted string",
+			script: `INSERT INTO t VALUE("a;b");`,
+			want:   []string{`INSERT INTO t VALUE("a;b")`},
+		},
+		{
+			name:   "semicolon in a quoted identifier",
+			script: "CREATE TABLE `odd;name` (id INT);",
+			want:   []string{"CREATE TABLE `odd;name` (id INT)"},
+		},
+		{
+			name:   "escaped and doubled quotes",
+			script: `INSERT INTO t VALUE('it\'s;', 'it''s;');DROP TABLE a;`,
+			want:   []string{`INSERT INTO t VALUE('it\'s;', 'it''s;')`, "DROP TABLE a"},
+		},
+		{
+			name:   "dash comment",
+			script: "-- drop it; really\nDROP TABLE a; -- done;\n",
+			want:   []string{"DROP TABLE a"},
+		},
+		{
+			name:   "hash comment",
+			script: "# it's gone;\nDROP TABLE a;\n# trailing comment",
+			want:   []string{"DROP TABLE a"},
+		},
+		{
+			name:   "block comment",
+			script: "/* first; 'unbalanced */DROP TABLE a;\nDROP /* inline; */TABLE b;",
+			want:   []string{"DROP TABLE a", "DROP TABLE b"},
+		},
+		{
+			name:   "unterminated block comment",
+			script: "DROP TABLE a;\n/* DROP TABLE b;",
+			want:   []string{"DROP TABLE a"},
+		},
+		{
+			name:   "comment markers in strings",
+			script: "INSERT INTO t VALUE('-- no', '# no', '/* no */');",
+			want:   []string{"INSERT INTO t VALUE('-- no', '# no', '/* no */')"},
+		},
+		{
+			name:   "minus that starts no comment",
+			script: "UPDATE t SET n = n -1;",
+			want:   []string{"UPDATE t SET n = n -1"},
+		},
+		{
+			name:   "only comments",
+			script: "-- nothing here\n# or here\n/* or here */\n",
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i+1, m.Version)
+		}
+		if len(statements(m.Up)) == 0 {
+			t.Errorf("migration %04d_%s has no up statements", m.Version, m.Name)
+		}
+		if len(statements(m.Down)) == 0 {
+			t.Errorf("migration %04d_%s has no down statements", m.Version, m.Name)
+		}
+	}
+}
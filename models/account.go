@@ -0,0 +1,314 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxFullNameLength = 255
+	maxAvatarLength   = 512
+	maxAboutLength    = 4096
+
+	MinPasswordLength = 8
+)
+
+// What DELETE /users/self/ does with the courses the user owns.
+const (
+	// OwnedCoursesTransfer hands every owned course to one of its teachers. Courses nobody else takes
+	// part in are deleted, and courses others take part in that have no teacher make the deletion fail.
+	OwnedCoursesTransfer = "transfer"
+	// OwnedCoursesDelete deletes every owned course.
+	OwnedCoursesDelete = "delete"
+)
+
+// UserUpdateInput changes the fields of a profile that are set.
+type UserUpdateInput struct {
+	FullName *string `json:"full_name"`
+	Avatar   *string `json:"avatar"`
+	About    *string `json:"about"`
+}
+
+type PasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// AccountDeleteInput confirms the deletion of an account with its password. OwnedCourses is
+// OwnedCoursesTransfer or OwnedCoursesDelete, transfer by default.
+type AccountDeleteInput struct {
+	Password     string `json:"password" binding:"required"`
+	OwnedCourses string `json:"owned_courses"`
+}
+
+type IUserEditor interface {
+	Update(ctx context.Context, id int64, in UserUpdateInput) error
+	IUserGetter
+}
+
+type IPasswordChecker interface {
+	CheckUserPassword(ctx context.Context, id int64, password string) (bool, error)
+}
+
+type IPasswordChanger interface {
+	SetPassword(ctx context.Context, id int64, password string) error
+	IPasswordChecker
+}
+
+type IAccountDeleter interface {
+	Delete(ctx context.Context, id int64, ownedCourses string) error
+	IPasswordChecker
+}
+
+// validate trims the full name and checks the lengths of the fields and that the avatar is a link.
+func (in *UserUpdateInput) validate() error {
+	if in.FullName != nil {
+		name := strings.TrimSpace(*in.FullName)
+		in.FullName = &name
+
+		if len([]rune(name)) > maxFullNameLength {
+			return ValidationError(fmt.Sprintf("full_name can be at most %d characters long", maxFullNameLength))
+		}
+	}
+
+	if in.Avatar != nil && *in.Avatar != "" {
+		if len(*in.Avatar) > maxAvatarLength || !isWebURL(*in.Avatar) {
+			return ValidationError(fmt.Sprintf("avatar must be an http(s) link of at most %d characters", maxAvatarLength))
+		}
+	}
+
+	if in.About != nil && len([]rune(*in.About)) > maxAboutLength {
+		return ValidationError(fmt.Sprintf("about can be at most %d characters long", maxAboutLength))
+	}
+
+	return nil
+}
+
+func (m ModelUser) Update(ctx context.Context, id int64, in UserUpdateInput) error {
+	if err := in.validate(); err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET
+			full_name = COALESCE(?, full_name),
+			avatar = COALESCE(?, avatar),
+			about = COALESCE(?, about)
+		WHERE id = ?
+	`, in.FullName, in.Avatar, in.About, id)
+	if err != nil {
+		return err
+	}
+
+	if in.Avatar != nil {
+		if err = syncFileRefs(ctx, tx, refUser, id, *in.Avatar); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CheckUserPassword reports whether password is the user's current password.
+func (m ModelUser) CheckUserPassword(ctx context.Context, id int64, password string) (bool, error) {
+	var stored string
+
+	err := m.db.QueryRowContext(ctx, `SELECT password_hash FROM users WHERE id = ?`, id).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ok, _ := CheckPassword(stored, password)
+
+	return ok, nil
+}
+
+// validatePassword checks that the password given in field is long enough, the same for registration
+// and password changes.
+func validatePassword(field string, password string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return ValidationError(fmt.Sprintf("%s must be at least %d characters long", field, MinPasswordLength))
+	}
+
+	return nil
+}
+
+// SetPassword replaces the user's password and revokes their refresh tokens, so that every other
+// session has to log in again.
+func (m ModelUser) SetPassword(ctx context.Context, id int64, password string) error {
+	if err := validatePassword("new_password", password); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, hash, id)
+	if err != nil {
+		return err
+	}
+	if err = requireAffected(res); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the account. The owned courses are handed over or deleted as ownedCourses says,
+// the user leaves the courses they study, so that their seats go to the waitlists, and everything
+// else of theirs goes with the account: mentorships, invitations, enrollment requests, progress,
+// attempts and submissions. Their uploaded files are kept until nothing references them.
+func (m ModelUser) Delete(ctx context.Context, id int64, ownedCourses string) error {
+	if ownedCourses == "" {
+		ownedCourses = OwnedCoursesTransfer
+	}
+	if ownedCourses != OwnedCoursesTransfer && ownedCourses != OwnedCoursesDelete {
+		return ValidationError(fmt.Sprintf("owned_courses must be %q or %q", OwnedCoursesTransfer, OwnedCoursesDelete))
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if ownedCourses == OwnedCoursesTransfer {
+		err = transferOwnedCourses(ctx, tx, id)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM courses WHERE owner_id = ?`, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	courseIDs, err := selectIDs(ctx, tx, `SELECT course_id FROM students WHERE user_id = ?`, id)
+	if err != nil {
+		return err
+	}
+	for _, courseID := range courseIDs {
+		if err = leaveCourse(ctx, tx, courseID, id); err != nil {
+			return err
+		}
+	}
+
+	// The refresh tokens go first, so that no session outlives the account by a refresh.
+	if _, err = tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// transferOwnedCourses makes the first teacher of each course the user owns its owner. Courses
+// without students or mentors are deleted; any other course without a teacher fails the transfer.
+func transferOwnedCourses(ctx context.Context, tx *sql.Tx, ownerID int64) error {
+	courseIDs, err := selectIDs(ctx, tx, `SELECT id FROM courses WHERE owner_id = ? FOR UPDATE`, ownerID)
+	if err != nil {
+		return err
+	}
+
+	stranded := make([]int64, 0)
+	for _, courseID := range courseIDs {
+		var teacherID int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT user_id FROM mentors WHERE course_id = ? AND role = ? ORDER BY user_id LIMIT 1
+		`, courseID, MentorRoleTeacher).Scan(&teacherID)
+
+		switch {
+		case err == nil:
+			if _, err = tx.ExecContext(ctx, `UPDATE courses SET owner_id = ? WHERE id = ?`, teacherID, courseID); err != nil {
+				return err
+			}
+			// The owner can't also be a mentor of the course.
+			_, err = tx.ExecContext(ctx, `DELETE FROM mentors WHERE course_id = ? AND user_id = ?`, courseID, teacherID)
+			if err != nil {
+				return err
+			}
+		case err == sql.ErrNoRows:
+			var others int
+			err = tx.QueryRowContext(ctx, `
+				SELECT
+					(SELECT COUNT(*) FROM students WHERE course_id = ?) +
+					(SELECT COUNT(*) FROM mentors WHERE course_id = ?)
+			`, courseID, courseID).Scan(&others)
+			if err != nil {
+				return err
+			}
+
+			if others > 0 {
+				stranded = append(stranded, courseID)
+				continue
+			}
+			if _, err = tx.ExecContext(ctx, `DELETE FROM courses WHERE id = ?`, courseID); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+
+	if len(stranded) > 0 {
+		return ConflictError(fmt.Sprintf(
+			"courses %v have no teacher to take them over; add one or delete them with owned_courses %q",
+			stranded, OwnedCoursesDelete,
+		))
+	}
+
+	return nil
+}
+
+// selectIDs runs a query selecting one id column and returns the ids.
+func selectIDs(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,421 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Submission statuses. A student submits, a mentor either reviews the submission, which is final,
+// or returns it for another try, after which the student can submit again.
+const (
+	SubmissionSubmitted = "submitted"
+	SubmissionReviewed  = "reviewed"
+	SubmissionReturned  = "returned"
+)
+
+type Assignment struct {
+	ID          int       `json:"id"`
+	LessonID    int       `json:"lesson_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	DueDate     time.Time `json:"due_date"`
+	MaxScore    int       `json:"max_score"`
+}
+
+type AssignmentInput struct {
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	DueDate     time.Time `json:"due_date" binding:"required"`
+	MaxScore    int       `json:"max_score" binding:"required"`
+}
+
+type Submission struct {
+	ID            int        `json:"id"`
+	AssignmentID  int        `json:"assignment_id"`
+	UserID        int        `json:"user_id"`
+	FileURL       string     `json:"file_url"`
+	FileID        *int64     `json:"file_id"`
+	Comment       string     `json:"comment"`
+	Status        string     `json:"status"`
+	Score         *int       `json:"score"`
+	Feedback      string     `json:"feedback"`
+	Late          bool       `json:"late"`
+	DateSubmitted time.Time  `json:"date_submitted"`
+	DateReviewed  *time.Time `json:"date_reviewed"`
+}
+
+// SubmissionInput takes the work either as the URL of a file or as the id of a file the student uploaded.
+type SubmissionInput struct {
+	FileURL string `json:"file_url"`
+	FileID  *int64 `json:"file_id"`
+	Comment string `json:"comment"`
+}
+
+type ReviewInput struct {
+	Score    *int   `json:"score"`
+	Feedback string `json:"feedback"`
+}
+
+var (
+	ErrSubmissionFileURL error = ValidationError("file_url must be an http(s) link to the work, or file_id the id of a file you uploaded")
+	ErrSubmissionClosed  error = ConflictError("the submission was already reviewed")
+	ErrSubmissionState   error = ConflictError("only submitted work can be reviewed or returned")
+	ErrScoreOutOfRange   error = ValidationError("score must be between 0 and the assignment's max score")
+)
+
+type ModelAssignment struct {
+	model
+}
+
+type IAssignmentLister interface {
+	GetList(ctx context.Context, lessonID int64) ([]Assignment, error)
+}
+
+type IAssignmentGetter interface {
+	Get(ctx context.Context, id int64) (Assignment, error)
+}
+
+type IAssignmentCreator interface {
+	Create(ctx context.Context, lessonID int64, in AssignmentInput) (int64, error)
+	IAssignmentGetter
+}
+
+type IAssignmentUpdater interface {
+	Update(ctx context.Context, id int64, in AssignmentInput) error
+	IAssignmentGetter
+}
+
+type IAssignmentDeleter interface {
+	Delete(ctx context.Context, id int64) error
+	IAssignmentGetter
+}
+
+type ISubmissionCreator interface {
+	Submit(ctx context.Context, assignment Assignment, userID int64, in SubmissionInput) (int64, error)
+	GetSubmission(ctx context.Context, id int64) (Submission, error)
+	IAssignmentGetter
+}
+
+type ISubmissionReviewer interface {
+	SetReview(ctx context.Context, submission Submission, status string, in ReviewInput) error
+	GetSubmission(ctx context.Context, id int64) (Submission, error)
+	IAssignmentGetter
+}
+
+type ISubmissionLister interface {
+	GetCourseSubmissions(ctx context.Context, courseID int64, status string) ([]Submission, error)
+	GetUserSubmissions(ctx context.Context, userID int64, status string) ([]Submission, error)
+}
+
+func NewAssignmentModel(db *sql.DB) ModelAssignment {
+	return ModelAssignment{model{db}}
+}
+
+func (m ModelAssignment) GetList(ctx context.Context, lessonID int64) ([]Assignment, error) {
+	assignments := make([]Assignment, 0)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			id, lesson_id, title, description, due_date, max_score
+		FROM assignments
+		WHERE lesson_id = ?
+		ORDER BY due_date, id
+	`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assignment Assignment
+
+		err = rows.Scan(&assignment.ID, &assignment.LessonID, &assignment.Title, &assignment.Description,
+			&assignment.DueDate, &assignment.MaxScore)
+		if err != nil {
+			return nil, err
+		}
+
+		assignments = append(assignments, assignment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+func (m ModelAssignment) Get(ctx context.Context, id int64) (Assignment, error) {
+	assignment := Assignment{}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			id, lesson_id, title, description, due_date, max_score
+		FROM assignments
+		WHERE id = ?
+	`, id)
+
+	err := row.Scan(&assignment.ID, &assignment.LessonID, &assignment.Title, &assignment.Description,
+		&assignment.DueDate, &assignment.MaxScore)
+	if err == sql.ErrNoRows {
+		return Assignment{}, ErrNotFound
+	}
+	if err != nil {
+		return Assignment{}, err
+	}
+
+	return assignment, nil
+}
+
+func (m ModelAssignment) Create(ctx context.Context, lessonID int64, in AssignmentInput) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO assignments(
+			lesson_id, title, description, due_date, max_score
+		) VALUE(?, ?, ?, ?, ?)
+	`, lessonID, in.Title, in.Description, in.DueDate, in.MaxScore)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = syncFileRefs(ctx, tx, refAssignment, id, textURLs(in.Description)...); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (m ModelAssignment) Update(ctx context.Context, id int64, in AssignmentInput) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE assignments SET
+			title = ?,
+			description = ?,
+			due_date = ?,
+			max_score = ?
+		WHERE id = ?
+	`, in.Title, in.Description, in.DueDate, in.MaxScore, id)
+	if err != nil {
+		return err
+	}
+
+	if err = syncFileRefs(ctx, tx, refAssignment, id, textURLs(in.Description)...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ModelAssignment) Delete(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM assignments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// Submit stores the student's work. A student has one submission per assignment: submitting again
+// replaces the file while it waits for review or after it was returned, but not once it was reviewed.
+func (m ModelAssignment) Submit(ctx context.Context, assignment Assignment, userID int64, in SubmissionInput) (int64, error) {
+	if in.FileID == nil && !isWebURL(in.FileURL) {
+		return 0, ErrSubmissionFileURL
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if in.FileID != nil {
+		if in.FileURL, err = fileURL(ctx, tx, *in.FileID, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	var id int64
+	var status string
+
+	row := tx.QueryRowContext(ctx, `
+		SELECT id, status FROM submissions WHERE assignment_id = ? AND user_id = ? FOR UPDATE
+	`, assignment.ID, userID)
+
+	err = row.Scan(&id, &status)
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO submissions(
+				assignment_id, user_id, file_url, file_id, comment, status, late, date_submitted
+			) VALUE(?, ?, ?, ?, ?, ?, NOW() > ?, NOW())
+		`, assignment.ID, userID, in.FileURL, in.FileID, in.Comment, SubmissionSubmitted, assignment.DueDate)
+		if err != nil {
+			return 0, err
+		}
+
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	case status == SubmissionReviewed:
+		return 0, ErrSubmissionClosed
+	default:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE submissions SET
+				file_url = ?,
+				file_id = ?,
+				comment = ?,
+				status = ?,
+				late = NOW() > ?,
+				date_submitted = NOW()
+			WHERE id = ?
+		`, in.FileURL, in.FileID, in.Comment, SubmissionSubmitted, assignment.DueDate, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = syncFileRefs(ctx, tx, refSubmission, id, in.FileURL); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func scanSubmission(scanner interface{ Scan(...interface{}) error }) (Submission, error) {
+	var submission Submission
+	var score sql.NullInt64
+
+	err := scanner.Scan(&submission.ID, &submission.AssignmentID, &submission.UserID, &submission.FileURL,
+		&submission.FileID, &submission.Comment, &submission.Status, &score, &submission.Feedback, &submission.Late,
+		&submission.DateSubmitted, &submission.DateReviewed)
+	if err != nil {
+		return Submission{}, err
+	}
+
+	if score.Valid {
+		value := int(score.Int64)
+		submission.Score = &value
+	}
+
+	return submission, nil
+}
+
+const submissionColumns = `
+	s.id, s.assignment_id, s.user_id, s.file_url, s.file_id, s.comment, s.status, s.score, s.feedback, s.late,
+	s.date_submitted, s.date_reviewed
+`
+
+func (m ModelAssignment) GetSubmission(ctx context.Context, id int64) (Submission, error) {
+	row := m.db.QueryRowContext(ctx, `SELECT `+submissionColumns+` FROM submissions s WHERE s.id = ?`, id)
+
+	submission, err := scanSubmission(row)
+	if err == sql.ErrNoRows {
+		return Submission{}, ErrNotFound
+	}
+	if err != nil {
+		return Submission{}, err
+	}
+
+	return submission, nil
+}
+
+// SetReview moves a submitted work to the reviewed or returned status with the mentor's score and feedback.
+func (m ModelAssignment) SetReview(ctx context.Context, submission Submission, status string, in ReviewInput) error {
+	if submission.Status != SubmissionSubmitted {
+		return ErrSubmissionState
+	}
+
+	if in.Score != nil {
+		assignment, err := m.Get(ctx, int64(submission.AssignmentID))
+		if err != nil {
+			return err
+		}
+		if *in.Score < 0 || *in.Score > assignment.MaxScore {
+			return ErrScoreOutOfRange
+		}
+	}
+
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE submissions SET
+			status = ?,
+			score = ?,
+			feedback = ?,
+			date_reviewed = NOW()
+		WHERE id = ? AND status = ?
+	`, status, in.Score, in.Feedback, submission.ID, SubmissionSubmitted)
+	if err != nil {
+		return err
+	}
+
+	// Someone else reviewed it or the student resubmitted in between.
+	if err = requireAffected(res); err == ErrNotFound {
+		return ErrSubmissionState
+	}
+
+	return err
+}
+
+func (m ModelAssignment) querySubmissions(ctx context.Context, query string, args ...interface{}) ([]Submission, error) {
+	submissions := make([]Submission, 0)
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		submissions = append(submissions, submission)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return submissions, nil
+}
+
+// GetCourseSubmissions returns the submissions to every assignment of the course. An empty status returns all.
+func (m ModelAssignment) GetCourseSubmissions(ctx context.Context, courseID int64, status string) ([]Submission, error) {
+	return m.querySubmissions(ctx, `
+		SELECT `+submissionColumns+`
+		FROM submissions s
+		JOIN assignments a ON a.id = s.assignment_id
+		JOIN lessons l ON l.id = a.lesson_id
+		WHERE l.course_id = ? AND (? = '' OR s.status = ?)
+		ORDER BY s.date_submitted
+	`, courseID, status, status)
+}
+
+// GetUserSubmissions returns the user's own submissions. An empty status returns all.
+func (m ModelAssignment) GetUserSubmissions(ctx context.Context, userID int64, status string) ([]Submission, error) {
+	return m.querySubmissions(ctx, `
+		SELECT `+submissionColumns+`
+		FROM submissions s
+		WHERE s.user_id = ? AND (? = '' OR s.status = ?)
+		ORDER BY s.date_submitted DESC
+	`, userID, status, status)
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+type Category struct {
+	ID       int64  `json:"id"`
+	ParentID *int64 `json:"parent_id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+}
+
+type CategoryInput struct {
+	Slug       string
+	Name       string
+	ParentSlug string
+}
+
+type ModelCategory struct {
+	model
+}
+
+type ICategoryLister interface {
+	GetList(ctx context.Context) ([]Category, error)
+}
+
+type ICategoryEditor interface {
+	Create(ctx context.Context, in CategoryInput) (int64, error)
+	Delete(ctx context.Context, slug string) error
+	ICategoryLister
+}
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+	ErrCategoryInUse error = ConflictError("the category has subcategories")
+)
+
+func NewCategoryModel(db *sql.DB) ModelCategory {
+	return ModelCategory{model{db}}
+}
+
+// GetList returns every category, parents before their children.
+func (m ModelCategory) GetList(ctx context.Context) ([]Category, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM categories WHERE parent_id IS NULL
+			UNION ALL
+			SELECT c.id, tree.depth + 1 FROM categories c JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT
+			c.id, c.parent_id, c.slug, c.name
+		FROM categories c
+		JOIN tree ON tree.id = c.id
+		ORDER BY tree.depth, c.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Category, 0)
+	for rows.Next() {
+		var category Category
+		if err = rows.Scan(&category.ID, &category.ParentID, &category.Slug, &category.Name); err != nil {
+			return nil, err
+		}
+		list = append(list, category)
+	}
+
+	return list, rows.Err()
+}
+
+// Create adds a category, under the category with ParentSlug when it is set.
+func (m ModelCategory) Create(ctx context.Context, in CategoryInput) (int64, error) {
+	if !slugPattern.MatchString(in.Slug) || len(in.Slug) > 64 {
+		return 0, ValidationError(fmt.Sprintf("slug must be lowercase words joined by dashes, got %q", in.Slug))
+	}
+	if in.Name == "" {
+		return 0, ValidationError("category name is required")
+	}
+
+	var parentID *int64
+	if in.ParentSlug != "" {
+		var id int64
+		err := m.db.QueryRowContext(ctx, `SELECT id FROM categories WHERE slug = ?`, in.ParentSlug).Scan(&id)
+		if err == sql.ErrNoRows {
+			return 0, ValidationError(fmt.Sprintf("parent category %q does not exist", in.ParentSlug))
+		}
+		if err != nil {
+			return 0, err
+		}
+		parentID = &id
+	}
+
+	var taken int
+	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE slug = ?`, in.Slug).Scan(&taken)
+	if err != nil {
+		return 0, err
+	}
+	if taken > 0 {
+		return 0, ConflictError(fmt.Sprintf("category %q already exists", in.Slug))
+	}
+
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO categories(parent_id, slug, name) VALUE(?, ?, ?)
+	`, parentID, in.Slug, in.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// Delete removes a category without subcategories. Its courses are left uncategorized.
+func (m ModelCategory) Delete(ctx context.Context, slug string) error {
+	var children int
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM categories c JOIN categories parent ON parent.id = c.parent_id
+		WHERE parent.slug = ?
+	`, slug).Scan(&children)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrCategoryInUse
+	}
+
+	res, err := m.db.ExecContext(ctx, `DELETE FROM categories WHERE slug = ?`, slug)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// validateCategory checks that the category a course is put in exists. A nil id leaves the course uncategorized.
+func validateCategory(ctx context.Context, tx *sql.Tx, id *int64) error {
+	if id == nil {
+		return nil
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE id = ?`, *id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ValidationError(fmt.Sprintf("category %d does not exist", *id))
+	}
+
+	return nil
+}
@@ -0,0 +1,405 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+)
+
+const (
+	ComponentText  = "text"
+	ComponentImage = "image"
+	ComponentVideo = "video"
+	ComponentCode  = "code"
+	ComponentQuiz  = "quiz"
+	ComponentFile  = "file"
+)
+
+// Component is a content block of a lesson. Content holds one of the *Content structs below, chosen by Type.
+type Component struct {
+	ID       int             `json:"id"`
+	LessonID int             `json:"lesson_id"`
+	Number   int             `json:"number"`
+	Type     string          `json:"type"`
+	Content  json.RawMessage `json:"content"`
+}
+
+type ComponentInput struct {
+	Type    string          `json:"type" binding:"required"`
+	Content json.RawMessage `json:"content" binding:"required"`
+}
+
+type TextContent struct {
+	Markdown string `json:"markdown"`
+}
+
+// Image, video and file contents take either a URL or the id of an uploaded file, whose URL is filled in.
+
+type ImageContent struct {
+	URL     string `json:"url"`
+	FileID  *int64 `json:"file_id,omitempty"`
+	Caption string `json:"caption"`
+}
+
+type VideoContent struct {
+	URL    string `json:"url"`
+	FileID *int64 `json:"file_id,omitempty"`
+	Title  string `json:"title"`
+}
+
+type FileContent struct {
+	URL    string `json:"url"`
+	FileID *int64 `json:"file_id,omitempty"`
+	Name   string `json:"name"`
+}
+
+type CodeContent struct {
+	Language string `json:"language"`
+	Source   string `json:"source"`
+}
+
+type QuizContent struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Answer   int      `json:"answer"`
+}
+
+// WithoutAnswer returns the component with the correct answer removed from quiz content, for callers
+// who take the quiz rather than edit it. Other components are returned unchanged.
+func (c Component) WithoutAnswer() Component {
+	if c.Type != ComponentQuiz {
+		return c
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if json.Unmarshal(c.Content, &fields) != nil {
+		return c
+	}
+	delete(fields, "answer")
+
+	content, err := json.Marshal(fields)
+	if err != nil {
+		return c
+	}
+	c.Content = content
+
+	return c
+}
+
+var (
+	ErrComponentType    error = ValidationError("unknown component type")
+	ErrComponentContent error = ValidationError("component content is missing required fields")
+)
+
+func isWebURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// NormalizeComponent checks that content matches the component type and returns it re-encoded
+// without unknown fields.
+func NormalizeComponent(componentType string, content json.RawMessage) (json.RawMessage, error) {
+	var value interface{}
+	var valid bool
+
+	switch componentType {
+	case ComponentText:
+		v := TextContent{}
+		valid = json.Unmarshal(content, &v) == nil && v.Markdown != ""
+		value = v
+	case ComponentImage:
+		v := ImageContent{}
+		valid = json.Unmarshal(content, &v) == nil && (isWebURL(v.URL) || v.FileID != nil)
+		value = v
+	case ComponentVideo:
+		v := VideoContent{}
+		valid = json.Unmarshal(content, &v) == nil && (isWebURL(v.URL) || v.FileID != nil)
+		value = v
+	case ComponentFile:
+		v := FileContent{}
+		valid = json.Unmarshal(content, &v) == nil && (isWebURL(v.URL) || v.FileID != nil)
+		value = v
+	case ComponentCode:
+		v := CodeContent{}
+		valid = json.Unmarshal(content, &v) == nil && v.Source != ""
+		value = v
+	case ComponentQuiz:
+		v := QuizContent{}
+		valid = json.Unmarshal(content, &v) == nil && v.Question != "" &&
+			len(v.Options) > 1 && v.Answer >= 0 && v.Answer < len(v.Options)
+		value = v
+	default:
+		return nil, ErrComponentType
+	}
+
+	if !valid {
+		return nil, ErrComponentContent
+	}
+
+	return json.Marshal(value)
+}
+
+type ModelComponent struct {
+	model
+}
+
+type IComponentLister interface {
+	GetList(ctx context.Context, lessonID int64) ([]Component, error)
+}
+
+type IComponentGetter interface {
+	Get(ctx context.Context, id int64) (Component, error)
+}
+
+type IComponentCreator interface {
+	Create(ctx context.Context, lessonID int64, userID int64, in ComponentInput) (int64, error)
+	IComponentGetter
+}
+
+type IComponentUpdater interface {
+	Update(ctx context.Context, in Component, userID int64) error
+	IComponentGetter
+}
+
+type IComponentDeleter interface {
+	Delete(ctx context.Context, id int64) error
+	IComponentGetter
+}
+
+type IComponentReorderer interface {
+	Reorder(ctx context.Context, lessonID int64, componentIDs []int64) error
+	IComponentLister
+}
+
+func NewComponentModel(db *sql.DB) ModelComponent {
+	return ModelComponent{model{db}}
+}
+
+// selectComponents returns the components of a lesson in display order.
+func selectComponents(ctx context.Context, db *sql.DB, lessonID int64) ([]Component, error) {
+	components := make([]Component, 0)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			id, lesson_id, number, type, content
+		FROM lesson_components
+		WHERE lesson_id = ?
+		ORDER BY number
+	`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var component Component
+
+		err = rows.Scan(&component.ID, &component.LessonID, &component.Number, &component.Type, &component.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		components = append(components, component)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return components, nil
+}
+
+func (m ModelComponent) GetList(ctx context.Context, lessonID int64) ([]Component, error) {
+	return selectComponents(ctx, m.db, lessonID)
+}
+
+func (m ModelComponent) Get(ctx context.Context, id int64) (Component, error) {
+	component := Component{}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			id, lesson_id, number, type, content
+		FROM lesson_components
+		WHERE id = ?
+	`, id)
+
+	err := row.Scan(&component.ID, &component.LessonID, &component.Number, &component.Type, &component.Content)
+	if err == sql.ErrNoRows {
+		return Component{}, ErrNotFound
+	}
+	if err != nil {
+		return Component{}, err
+	}
+
+	return component, nil
+}
+
+// fileContent returns the content struct of a component type that can refer to an uploaded file,
+// with pointers to its file id and URL, or a nil value for the other types.
+func fileContent(componentType string) (value interface{}, fileID **int64, url *string) {
+	switch componentType {
+	case ComponentImage:
+		v := &ImageContent{}
+		return v, &v.FileID, &v.URL
+	case ComponentVideo:
+		v := &VideoContent{}
+		return v, &v.FileID, &v.URL
+	case ComponentFile:
+		v := &FileContent{}
+		return v, &v.FileID, &v.URL
+	}
+
+	return nil, nil, nil
+}
+
+// contentFileID returns the id of the uploaded file the content refers to, or 0.
+func contentFileID(componentType string, content json.RawMessage) int64 {
+	value, fileID, _ := fileContent(componentType)
+	if value == nil || json.Unmarshal(content, value) != nil || *fileID == nil {
+		return 0
+	}
+
+	return **fileID
+}
+
+// resolveFile fills in the URL of content that refers to an uploaded file by id. The file must be
+// one of userID's uploads, unless it is keptID, the file the component showed already, which another
+// mentor may have uploaded.
+func resolveFile(ctx context.Context, q rowQuerier, componentType string, content json.RawMessage, userID int64, keptID int64) (json.RawMessage, error) {
+	value, fileID, url := fileContent(componentType)
+	if value == nil {
+		return content, nil
+	}
+
+	if err := json.Unmarshal(content, value); err != nil {
+		return nil, err
+	}
+	if *fileID == nil {
+		return content, nil
+	}
+
+	ownerID := userID
+	if **fileID == keptID {
+		ownerID = 0
+	}
+
+	var err error
+	if *url, err = fileURL(ctx, q, **fileID, ownerID); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// contentURLs returns the links in the content, the url field of image, video and file contents as
+// well as the ones written into markdown.
+func contentURLs(content json.RawMessage) []string {
+	return textURLs(string(content))
+}
+
+// Create appends the component to the end of the lesson. The content must already be normalized and
+// may only refer to files the user uploaded.
+func (m ModelComponent) Create(ctx context.Context, lessonID int64, userID int64, in ComponentInput) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if in.Content, err = resolveFile(ctx, tx, in.Type, in.Content, userID, 0); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO lesson_components(number, lesson_id, type, content)
+		SELECT COALESCE(MAX(number), 0) + 1, ?, ?, ?
+		FROM lesson_components
+		WHERE lesson_id = ?
+	`, lessonID, in.Type, []byte(in.Content), lessonID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = syncFileRefs(ctx, tx, refComponent, id, contentURLs(in.Content)...); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// Update replaces the type and content of the component. The content may keep the file the
+// component showed and otherwise only refer to files the user uploaded.
+func (m ModelComponent) Update(ctx context.Context, in Component, userID int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stored Component
+	err = tx.QueryRowContext(ctx, `
+		SELECT type, content FROM lesson_components WHERE id = ? FOR UPDATE
+	`, in.ID).Scan(&stored.Type, &stored.Content)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	keptID := contentFileID(stored.Type, stored.Content)
+	if in.Content, err = resolveFile(ctx, tx, in.Type, in.Content, userID, keptID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE lesson_components SET
+			type = ?,
+			content = ?
+		WHERE id = ?
+	`, in.Type, []byte(in.Content), in.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = syncFileRefs(ctx, tx, refComponent, int64(in.ID), contentURLs(in.Content)...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ModelComponent) Delete(ctx context.Context, id int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = deleteNumbered(ctx, tx, "lesson_components", "lesson_id", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reorder renumbers the lesson's components in the order of componentIDs, which must contain each of them once.
+func (m ModelComponent) Reorder(ctx context.Context, lessonID int64, componentIDs []int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = renumber(ctx, tx, "lesson_components", "lesson_id", lessonID, componentIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
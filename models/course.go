@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"strings"
 )
 
 type Mentor struct {
@@ -15,23 +17,39 @@ type CourseDetail struct {
 	Title         string   `json:"title"`
 	Description   string   `json:"description"`
 	Avatar        string   `json:"avatar"`
+	Status        string   `json:"status"`
 	StudentsCount int      `json:"students_count"`
 	OwnerID       int      `json:"owner_id"`
+	CategoryID    *int64   `json:"category_id"`
+	Tags          []string `json:"tags"`
 	Mentors       []Mentor `json:"mentors"`
 	Entered       bool     `json:"entered"`
+
+	Enrollment EnrollmentSettings `json:"enrollment"`
+
+	// Progress is only filled in for the caller's own enrollment.
+	Progress *CourseProgress `json:"progress,omitempty"`
 }
 
 type CourseCreateInput struct {
 	Avatar      string   `json:"avatar"`
 	Title       string   `json:"title"`
 	Description string   `json:"description"`
+	CategoryID  *int64   `json:"category_id"`
+	Tags        []string `json:"tags"`
 	Mentors     []Mentor `json:"mentors"`
+
+	Enrollment EnrollmentSettings `json:"enrollment"`
 }
 
 type CourseUpdateInput struct {
 	Title       string   `json:"title"`
 	Description string   `json:"description"`
+	CategoryID  *int64   `json:"category_id"`
+	Tags        []string `json:"tags"`
 	Mentors     []Mentor `json:"mentors"`
+
+	Enrollment EnrollmentSettings `json:"enrollment"`
 }
 
 type ModelCourse struct {
@@ -39,29 +57,29 @@ type ModelCourse struct {
 }
 
 type ICourseLister interface {
-	GetList(limit, offset int, userID int64, search string) []CourseDetail
-	GetListForUser(limit, offset int, userID int64, admin bool) []CourseDetail
-	CountForUser(userID int64, admin bool) int
-	Count() int
+	GetList(ctx context.Context, page PageRequest, userID int64, filter CourseFilter) ([]CourseDetail, PageInfo, error)
+	Count(ctx context.Context, userID int64, filter CourseFilter) (int, error)
+	Facets(ctx context.Context, userID int64, filter CourseFilter) (Facets, error)
 }
 
 type ICourseGetter interface {
-	Get(id int64) CourseDetail
-	Leave(courseID int64, userID int64)
-	Enter(courseID int64, userID int64)
+	Get(ctx context.Context, id int64) (CourseDetail, error)
+	Entered(ctx context.Context, courseID int64, userID int64) (bool, error)
+	Leave(ctx context.Context, courseID int64, userID int64) error
+	Enter(ctx context.Context, courseID int64, userID int64) (string, error)
 }
 
 type ICourseCreator interface {
-	Create(in CourseCreateInput, ownerID int64) int64
+	Create(ctx context.Context, in CourseCreateInput, ownerID int64) (int64, error)
 	ICourseGetter
 }
 
 type ICourseDeleter interface {
-	Delete(id int64)
+	Delete(ctx context.Context, id int64) error
 }
 
 type ICourseUpdater interface {
-	Update(in CourseDetail)
+	Update(ctx context.Context, in CourseDetail) error
 	ICourseGetter
 }
 
@@ -75,8 +93,8 @@ func NewCourseModel(db *sql.DB) ModelCourse {
 	return ModelCourse{model{db}}
 }
 
-func (m ModelCourse) Entered(courseID int64, userID int64) bool {
-	row := m.db.QueryRow(`
+func (m ModelCourse) Entered(ctx context.Context, courseID int64, userID int64) (bool, error) {
+	row := m.db.QueryRowContext(ctx, `
 		SELECT
        		progress
 		FROM students
@@ -85,221 +103,105 @@ func (m ModelCourse) Entered(courseID int64, userID int64) bool {
 
 	var progress float64 = -1.0
 	err := row.Scan(&progress)
-	if err != nil {
-		log.Println(err)
-		return false
-	}
-
-	if progress < 0 {
-		return false
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	return true
-}
-
-func (m ModelCourse) Enter(courseID int64, userID int64) {
-	stmt, err := m.db.Prepare(`
-		INSERT INTO students(
-			course_id, user_id
-		) VALUE(?, ?)
-	`)
 	if err != nil {
-		return
+		return false, err
 	}
 
-	_, err = stmt.Exec(courseID, userID)
-	if err != nil {
-		return
-	}
+	return progress >= 0, nil
 }
 
-func (m ModelCourse) Leave(courseID int64, userID int64) {
-	stmt, err := m.db.Prepare(`
-		DELETE FROM students WHERE course_id = ? AND user_id = ?
-	`)
-	if err != nil {
-		return
-	}
+// inClause returns "(?, ?, ...)" for the ids together with the ids as query arguments.
+func inClause(ids []int64) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
 
-	_, err = stmt.Exec(courseID, userID)
-	if err != nil {
-		return
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
 	}
-}
 
-func (m ModelCourse) GetList(limit, offset int, userID int64, search string) []CourseDetail {
-	courses := make([]CourseDetail, 0)
-
-	rows, err := m.db.Query(`
-		SELECT
-		       *
-		FROM courses
-		WHERE title LIKE ?
-		LIMIT ? OFFSET ?
-	`, "%"+search+"%", limit, offset)
-	if err != nil {
-		return courses
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var course CourseDetail
-
-		err = rows.Scan(&course.ID, &course.Title, &course.Description, &course.OwnerID, &course.Avatar)
-		if err != nil {
-			//
-			return courses
-		}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
 
-		course.Entered = m.Entered(course.ID, userID)
-		course.Mentors = m.GetMentorsList(course.ID)
-		course.StudentsCount = m.CountStudents(course.ID)
-		courses = append(courses, course)
+// fillListDetails loads the user's enrollment, tags and mentors for a page of courses with one query
+// each, instead of queries per course. Student counts come with the courses from students_count.
+func (m ModelCourse) fillListDetails(ctx context.Context, courses []CourseDetail, userID int64) error {
+	if len(courses) == 0 {
+		return nil
 	}
 
-	if err = rows.Err(); err != nil {
-		// log error
+	byID := make(map[int64]*CourseDetail, len(courses))
+	ids := make([]int64, len(courses))
+	for i := range courses {
+		courses[i].Mentors = make([]Mentor, 0)
+		courses[i].Tags = make([]string, 0)
+		byID[courses[i].ID] = &courses[i]
+		ids[i] = courses[i].ID
 	}
 
-	return courses
-}
-
-func (m ModelCourse) GetListForUser(limit, offset int, userID int64, admin bool) []CourseDetail {
-	courses := make([]CourseDetail, 0)
-
-	var rows *sql.Rows
-	var err error
-
-	if admin {
-		rows, err = m.db.Query(`
-			SELECT DISTINCT 
-				c.id, c.title, c.description, c.owner_id, c.avatar
-			FROM courses c
-			LEFT JOIN mentors m ON c.id = m.course_id
-			WHERE c.owner_id = ? OR m.user_id = ?
-			LIMIT ? OFFSET ?
-		`, userID, userID, limit, offset)
-	} else {
-		rows, err = m.db.Query(`
-			SELECT
-				id, title, description, owner_id, avatar
-			FROM courses c
-				JOIN students s ON c.id = s.course_id
-			WHERE s.user_id = ?
-			LIMIT ? OFFSET ?
-		`, userID, limit, offset)
-	}
+	in, args := inClause(ids)
 
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT course_id FROM students WHERE user_id = ? AND progress >= 0 AND course_id IN `+in,
+		append([]interface{}{userID}, args...)...)
 	if err != nil {
-		return courses
+		return err
 	}
-
 	defer rows.Close()
 
 	for rows.Next() {
-		var course CourseDetail
-
-		err = rows.Scan(&course.ID, &course.Title, &course.Description, &course.OwnerID, &course.Avatar)
-		if err != nil {
-			return courses
+		var courseID int64
+		if err = rows.Scan(&courseID); err != nil {
+			return err
 		}
 
-		course.Entered = m.Entered(course.ID, userID)
-		course.Mentors = m.GetMentorsList(course.ID)
-		course.StudentsCount = m.CountStudents(course.ID)
-		courses = append(courses, course)
+		byID[courseID].Entered = true
 	}
-
 	if err = rows.Err(); err != nil {
-		// log error
+		return err
 	}
 
-	return courses
-}
-
-func (m ModelCourse) Count() int {
-	rows, err := m.db.Query(`SELECT COUNT(*) FROM courses`)
-
+	tags, err := selectTags(ctx, m.db, ids)
 	if err != nil {
-		log.Println(err)
-		return 0
-	}
-	defer rows.Close()
-
-	var count int
-
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			log.Println(err)
-			return 0
-		}
+		return err
 	}
-
-	return count
-}
-
-func (m ModelCourse) CountForUser(userID int64, admin bool) int {
-	var rows *sql.Rows
-	var err error
-
-	if !admin {
-		rows, err = m.db.Query(`
-			SELECT
-			   COUNT(*)
-			FROM courses c JOIN students s ON c.id = s.course_id
-			WHERE s.user_id = ?
-		`, userID)
-	} else {
-		rows, err = m.db.Query(`
-			SELECT 
-				COUNT(*)
-			FROM courses
-			WHERE owner_id = ?
-		`, userID)
+	for courseID, list := range tags {
+		byID[courseID].Tags = list
 	}
 
+	mentorRows, err := m.db.QueryContext(ctx, `
+		SELECT
+			m.course_id, u.id, u.user_name, u.full_name, u.avatar, u.about, m.role
+		FROM users u
+		JOIN mentors m on u.id = m.user_id
+		WHERE m.course_id IN `+in, args...)
 	if err != nil {
-		log.Println(err)
-		return 0
+		return err
 	}
-	defer rows.Close()
+	defer mentorRows.Close()
 
-	var count int
+	for mentorRows.Next() {
+		var courseID int64
+		var mentor Mentor
 
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			log.Println(err)
-			return 0
+		err = mentorRows.Scan(&courseID, &mentor.ID, &mentor.Name, &mentor.FullName, &mentor.Avatar, &mentor.About, &mentor.Role)
+		if err != nil {
+			return err
 		}
-	}
-
-	return count
-}
 
-func (m ModelCourse) CountStudents(courseID int64) int {
-	rows, err := m.db.Query(`SELECT COUNT(*) FROM students WHERE course_id = ?`, courseID)
-
-	if err != nil {
-		log.Println(err)
-		return 0
-	}
-	defer rows.Close()
-
-	var count int
-
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			log.Println(err)
-			return 0
-		}
+		byID[courseID].Mentors = append(byID[courseID].Mentors, mentor)
 	}
 
-	return count
+	return mentorRows.Err()
 }
 
-func (m ModelCourse) GetMentorsList(courseID int64) []Mentor {
+func (m ModelCourse) GetMentorsList(ctx context.Context, courseID int64) ([]Mentor, error) {
 	mentors := make([]Mentor, 0)
 
-	rows, err := m.db.Query(`
+	rows, err := m.db.QueryContext(ctx, `
 		SELECT
 			u.id, u.user_name, u.full_name, u.avatar, u.about, m.role
 		FROM users u
@@ -307,7 +209,7 @@ func (m ModelCourse) GetMentorsList(courseID int64) []Mentor {
 		WHERE m.course_id = ?
 	`, courseID)
 	if err != nil {
-		return mentors
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -316,81 +218,202 @@ func (m ModelCourse) GetMentorsList(courseID int64) []Mentor {
 
 		err = rows.Scan(&mentor.ID, &mentor.Name, &mentor.FullName, &mentor.Avatar, &mentor.About, &mentor.Role)
 		if err != nil {
-			//
-			return mentors
+			return nil, err
 		}
 
 		mentors = append(mentors, mentor)
 	}
 
 	if err = rows.Err(); err != nil {
-		// log error
+		return nil, err
 	}
 
-	return mentors
+	return mentors, nil
 }
 
-func (m ModelCourse) Get(id int64) CourseDetail {
+func (m ModelCourse) Get(ctx context.Context, id int64) (CourseDetail, error) {
 	course := CourseDetail{}
 
-	row := m.db.QueryRow(`
+	row := m.db.QueryRowContext(ctx, `
 		SELECT
-		   id, title, description, avatar, owner_id
+		   id, title, description, avatar, status, owner_id, category_id,
+		   enrollment_mode, capacity, enrollment_opens, enrollment_closes, waitlist, students_count
 		FROM courses
 		WHERE id = ?
 	`, id)
-	err := row.Scan(&course.ID, &course.Title, &course.Description, &course.Avatar, &course.OwnerID)
+	err := row.Scan(
+		&course.ID, &course.Title, &course.Description, &course.Avatar, &course.Status, &course.OwnerID, &course.CategoryID,
+		&course.Enrollment.Mode, &course.Enrollment.Capacity, &course.Enrollment.OpensAt, &course.Enrollment.ClosesAt,
+		&course.Enrollment.Waitlist, &course.StudentsCount,
+	)
+	if err == sql.ErrNoRows {
+		return CourseDetail{}, ErrNotFound
+	}
 	if err != nil {
-		return CourseDetail{}
+		return CourseDetail{}, err
 	}
 
-	course.Mentors = m.GetMentorsList(id)
-	course.StudentsCount = m.CountStudents(id)
+	if course.Mentors, err = m.GetMentorsList(ctx, id); err != nil {
+		return CourseDetail{}, err
+	}
 
-	return course
+	tags, err := selectTags(ctx, m.db, []int64{id})
+	if err != nil {
+		return CourseDetail{}, err
+	}
+	course.Tags = append(make([]string, 0), tags[id]...)
+
+	return course, nil
 }
 
-func (m ModelCourse) Create(in CourseCreateInput, ownerID int64) int64 {
-	stmt, err := m.db.Prepare(`
-		INSERT INTO courses(
-			title, description, avatar, owner_id
-		) VALUE(?, ?, ?, ?)
-	`)
+// Create stores the course together with its mentors in one transaction.
+func (m ModelCourse) Create(ctx context.Context, in CourseCreateInput, ownerID int64) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	res, err := stmt.Exec(in.Title, in.Description, in.Avatar, ownerID)
+	if err = validateCategory(ctx, tx, in.CategoryID); err != nil {
+		return 0, err
+	}
+
+	enrollment := in.Enrollment.withDefaults()
+	if err = enrollment.validate(); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO courses(
+			title, description, avatar, status, owner_id, category_id,
+			enrollment_mode, capacity, enrollment_opens, enrollment_closes, waitlist
+		) VALUE(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, in.Title, in.Description, in.Avatar, CourseDraft, ownerID, in.CategoryID,
+		enrollment.Mode, enrollment.Capacity, enrollment.OpensAt, enrollment.ClosesAt, enrollment.Waitlist)
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
 	lastID, err := res.LastInsertId()
 	if err != nil {
-		return 0
+		return 0, err
+	}
+
+	if err = syncTags(ctx, tx, lastID, in.Tags); err != nil {
+		return 0, err
 	}
 
-	return lastID
+	if err = syncFileRefs(ctx, tx, refCourse, lastID, append(textURLs(in.Description), in.Avatar)...); err != nil {
+		return 0, err
+	}
+
+	if err = syncMentors(ctx, tx, lastID, ownerID, in.Mentors); err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
 }
 
-func (m ModelCourse) Delete(id int64) {
-	_, err := m.db.Exec(`DELETE FROM courses WHERE id = ?`, id)
+func (m ModelCourse) Delete(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM courses WHERE id = ?`, id)
 	if err != nil {
-		return
+		return err
 	}
+
+	return requireAffected(res)
 }
 
-func (m ModelCourse) Update(in CourseDetail) {
-	stmt, err := m.db.Prepare(`
+// Update stores the course fields and replaces its mentors with in.Mentors in one transaction. A new
+// owner must be an existing user. If they were a mentor or a student of the course they stop being
+// one, since the owner is neither. Waitlisted users enter when the capacity grows.
+func (m ModelCourse) Update(ctx context.Context, in CourseDetail) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int
+	err = tx.QueryRowContext(ctx, `SELECT owner_id FROM courses WHERE id = ? FOR UPDATE`, in.ID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if in.OwnerID != ownerID {
+		var newOwnerID int64
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ?`, in.OwnerID).Scan(&newOwnerID)
+		if err == sql.ErrNoRows {
+			return ValidationError(fmt.Sprintf("owner_id %d is not the id of a user", in.OwnerID))
+		}
+		if err != nil {
+			return err
+		}
+
+		// The owner doesn't study their own course: a student leaves it, handing the seat to the
+		// waitlist, and a pending request is withdrawn.
+		if err = leaveCourse(ctx, tx, in.ID, newOwnerID); err != nil {
+			return err
+		}
+
+		mentors := make([]Mentor, 0, len(in.Mentors))
+		for _, mentor := range in.Mentors {
+			if mentor.ID != in.OwnerID {
+				mentors = append(mentors, mentor)
+			}
+		}
+		in.Mentors = mentors
+	}
+
+	if err = validateCategory(ctx, tx, in.CategoryID); err != nil {
+		return err
+	}
+
+	enrollment := in.Enrollment.withDefaults()
+	if err = enrollment.validate(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
 		UPDATE courses SET
 			title = ?,
 			description  = ?,
 		    avatar = ?,
-		    owner_id = ?
-		WHERE id = ?`)
+		    owner_id = ?,
+		    category_id = ?,
+		    enrollment_mode = ?,
+		    capacity = ?,
+		    enrollment_opens = ?,
+		    enrollment_closes = ?,
+		    waitlist = ?
+		WHERE id = ?`, in.Title, in.Description, in.Avatar, in.OwnerID, in.CategoryID,
+		enrollment.Mode, enrollment.Capacity, enrollment.OpensAt, enrollment.ClosesAt, enrollment.Waitlist, in.ID)
+	if err != nil {
+		return err
+	}
+
+	if err = syncTags(ctx, tx, in.ID, in.Tags); err != nil {
+		return err
+	}
+
+	if err = syncFileRefs(ctx, tx, refCourse, in.ID, append(textURLs(in.Description), in.Avatar)...); err != nil {
+		return err
+	}
+
+	if err = syncMentors(ctx, tx, in.ID, int64(in.OwnerID), in.Mentors); err != nil {
+		return err
+	}
+
+	// Seats added by a raised or lifted capacity go to the waitlist right away.
+	state, err := lockEnrollmentState(ctx, tx, in.ID)
 	if err != nil {
-		return
+		return err
+	}
+	if err = admitWaitlisted(ctx, tx, in.ID, state); err != nil {
+		return err
 	}
 
-	_, err = stmt.Exec(in.Title, in.Description, in.Avatar, in.OwnerID, in.ID)
+	return tx.Commit()
 }
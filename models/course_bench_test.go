@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	"os"
+	"testing"
+	"time"
+)
+
+// The course list benchmarks run against a migrated MySQL database given by COURSIFY_BENCH_DSN, e.g.
+//
+//	COURSIFY_BENCH_DSN='user:pass@/coursify_bench?charset=utf8&parseTime=true' go test -run - -bench CourseList ./models
+//
+// They seed a page of courses with students, mentors and tags, which is deleted again afterwards.
+const (
+	benchCourses  = 50
+	benchStudents = 30
+	benchMentors  = 3
+)
+
+type courseListBench struct {
+	model   ModelCourse
+	page    []CourseDetail
+	ownerID int64
+	userID  int64
+}
+
+func benchExec(b *testing.B, db *sql.DB, query string, args ...interface{}) int64 {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	id, _ := res.LastInsertId()
+
+	return id
+}
+
+func benchUser(b *testing.B, db *sql.DB, prefix string, n int) int64 {
+	return benchExec(b, db, `
+		INSERT INTO users(user_name, full_name, about, password_hash, date_created)
+		VALUE(?, ?, '', '', NOW())
+	`, fmt.Sprintf("%s-%d", prefix, n), fmt.Sprintf("Bench User %d", n))
+}
+
+// seedCourseList fills the database and loads the seeded page of courses without its details.
+func seedCourseList(b *testing.B) courseListBench {
+	dsn := os.Getenv("COURSIFY_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("COURSIFY_BENCH_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	prefix := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+
+	users := make([]int64, benchStudents+benchMentors)
+	for i := range users {
+		users[i] = benchUser(b, db, prefix, i)
+	}
+	ownerID := benchUser(b, db, prefix, len(users))
+
+	b.Cleanup(func() {
+		db.Exec(`DELETE FROM courses WHERE owner_id = ?`, ownerID)
+		db.Exec(`DELETE FROM users WHERE user_name LIKE ?`, prefix+"-%")
+	})
+
+	tagID := benchExec(b, db, `INSERT INTO tags(name) VALUE(?)`, prefix)
+	b.Cleanup(func() { db.Exec(`DELETE FROM tags WHERE id = ?`, tagID) })
+
+	for i := 0; i < benchCourses; i++ {
+		courseID := benchExec(b, db, `
+			INSERT INTO courses(title, description, owner_id, status, students_count)
+			VALUE(?, '', ?, ?, ?)
+		`, fmt.Sprintf("%s course %d", prefix, i), ownerID, CoursePublished, benchStudents)
+
+		for _, userID := range users[:benchStudents] {
+			benchExec(b, db, `
+				INSERT INTO students(course_id, user_id, date_entered) VALUE(?, ?, NOW())
+			`, courseID, userID)
+		}
+		for _, userID := range users[benchStudents:] {
+			benchExec(b, db, `
+				INSERT INTO mentors(course_id, user_id, role) VALUE(?, ?, ?)
+			`, courseID, userID, MentorRoleTeacher)
+		}
+		benchExec(b, db, `INSERT INTO course_tags(course_id, tag_id) VALUE(?, ?)`, courseID, tagID)
+	}
+
+	bench := courseListBench{model: NewCourseModel(db), ownerID: ownerID, userID: users[0]}
+
+	page, _, err := bench.model.GetList(context.Background(), PageRequest{Limit: benchCourses}, bench.userID, CourseFilter{OwnerID: ownerID})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(page) != benchCourses {
+		b.Fatalf("listed %d seeded courses, want %d", len(page), benchCourses)
+	}
+	bench.page = page
+
+	return bench
+}
+
+// fillPerCourse loads the same details as fillListDetails the way the list did before, with queries
+// for every course.
+func (bench courseListBench) fillPerCourse(ctx context.Context, courses []CourseDetail) error {
+	m := bench.model
+
+	for i := range courses {
+		course := &courses[i]
+		var err error
+
+		err = m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM students WHERE course_id = ?`, course.ID).Scan(&course.StudentsCount)
+		if err != nil {
+			return err
+		}
+		if course.Entered, err = m.Entered(ctx, course.ID, bench.userID); err != nil {
+			return err
+		}
+		if course.Mentors, err = m.GetMentorsList(ctx, course.ID); err != nil {
+			return err
+		}
+
+		tags, err := selectTags(ctx, m.db, []int64{course.ID})
+		if err != nil {
+			return err
+		}
+		course.Tags = tags[course.ID]
+	}
+
+	return nil
+}
+
+func BenchmarkCourseListDetailsBatched(b *testing.B) {
+	bench := seedCourseList(b)
+	ctx := context.Background()
+	courses := make([]CourseDetail, len(bench.page))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(courses, bench.page)
+		if err := bench.model.fillListDetails(ctx, courses, bench.userID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCourseListDetailsPerCourse(b *testing.B) {
+	bench := seedCourseList(b)
+	ctx := context.Background()
+	courses := make([]CourseDetail, len(bench.page))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(courses, bench.page)
+		if err := bench.fillPerCourse(ctx, courses); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCourseGetList(b *testing.B) {
+	bench := seedCourseList(b)
+	ctx := context.Background()
+	page := PageRequest{Limit: benchCourses}
+	filter := CourseFilter{OwnerID: bench.ownerID}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := bench.model.GetList(ctx, page, bench.userID, filter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Enrollment modes of a course.
+const (
+	EnrollmentOpen       = "open"
+	EnrollmentApproval   = "approval"
+	EnrollmentInviteOnly = "invite_only"
+)
+
+// Statuses of an enrollment request. Requests of students who entered the course are dropped when they leave.
+const (
+	EnrollmentPending    = "pending"
+	EnrollmentWaitlisted = "waitlisted"
+	EnrollmentInvited    = "invited"
+	EnrollmentApproved   = "approved"
+	EnrollmentRejected   = "rejected"
+)
+
+// EnrollmentEntered is returned by Enter and Approve when the user became a student right away.
+const EnrollmentEntered = "entered"
+
+// EnrollmentSettings decide who can enter a course and when.
+type EnrollmentSettings struct {
+	Mode string `json:"mode"`
+	// Capacity is the maximum number of students, nil for no limit.
+	Capacity *int       `json:"capacity"`
+	OpensAt  *time.Time `json:"opens_at"`
+	ClosesAt *time.Time `json:"closes_at"`
+	// Waitlist queues users that find the course full. They enter in order as seats free up.
+	Waitlist bool `json:"waitlist"`
+}
+
+// EnrollmentRefusal explains why a user can't enter a course. Reason is a stable machine readable code.
+type EnrollmentRefusal struct {
+	Reason  string
+	Message string
+}
+
+func (e EnrollmentRefusal) Error() string {
+	return e.Message
+}
+
+var (
+	ErrEnrollmentNotPublished error = EnrollmentRefusal{"not_published", "the course is not published yet"}
+	ErrEnrollmentArchived     error = EnrollmentRefusal{"archived", "the course is archived"}
+	ErrEnrollmentNotOpen      error = EnrollmentRefusal{"not_open", "enrollment has not opened yet"}
+	ErrEnrollmentClosed       error = EnrollmentRefusal{"closed", "enrollment is closed"}
+	ErrEnrollmentInviteOnly   error = EnrollmentRefusal{"invite_only", "the course can only be entered by invitation"}
+	ErrEnrollmentFull         error = EnrollmentRefusal{"full", "the course has no free seats"}
+	ErrEnrollmentRejected     error = EnrollmentRefusal{"rejected", "your enrollment request was rejected"}
+
+	// ErrAlreadyEntered is returned by Enter when the user is already a student of the course.
+	ErrAlreadyEntered error = ConflictError("already entered the course")
+)
+
+// Enrollment is a request to enter a course that isn't settled yet, or was rejected.
+type Enrollment struct {
+	CourseID      int64      `json:"course_id"`
+	User          User       `json:"user"`
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason"`
+	DateRequested time.Time  `json:"date_requested"`
+	DateDecided   *time.Time `json:"date_decided"`
+}
+
+type EnrollmentInviteInput struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
+
+type EnrollmentRejectInput struct {
+	Reason string `json:"reason"`
+}
+
+type IEnrollmentManager interface {
+	GetEnrollments(ctx context.Context, courseID int64, status string) ([]Enrollment, error)
+	InviteStudent(ctx context.Context, courseID int64, userID int64, invitedBy int64) error
+	Approve(ctx context.Context, courseID int64, userID int64, decidedBy int64) (string, error)
+	Reject(ctx context.Context, courseID int64, userID int64, decidedBy int64, reason string) error
+}
+
+func (s EnrollmentSettings) validate() error {
+	switch s.Mode {
+	case EnrollmentOpen, EnrollmentApproval, EnrollmentInviteOnly:
+	default:
+		return ValidationError(fmt.Sprintf(
+			"enrollment mode must be %q, %q or %q, got %q", EnrollmentOpen, EnrollmentApproval, EnrollmentInviteOnly, s.Mode,
+		))
+	}
+
+	if s.Capacity != nil && *s.Capacity < 1 {
+		return ValidationError("capacity must be at least 1")
+	}
+
+	if s.OpensAt != nil && s.ClosesAt != nil && !s.OpensAt.Before(*s.ClosesAt) {
+		return ValidationError("enrollment must open before it closes")
+	}
+
+	return nil
+}
+
+// withDefaults fills in the mode of settings that left it out.
+func (s EnrollmentSettings) withDefaults() EnrollmentSettings {
+	if s.Mode == "" {
+		s.Mode = EnrollmentOpen
+	}
+
+	return s
+}
+
+// enrollmentState is what Enter and Approve need to know about a course, read with the course row locked.
+type enrollmentState struct {
+	status   string
+	settings EnrollmentSettings
+	students int
+}
+
+func lockEnrollmentState(ctx context.Context, tx *sql.Tx, courseID int64) (enrollmentState, error) {
+	state := enrollmentState{}
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			status, enrollment_mode, capacity, enrollment_opens, enrollment_closes, waitlist, students_count
+		FROM courses
+		WHERE id = ?
+		FOR UPDATE
+	`, courseID).Scan(
+		&state.status, &state.settings.Mode, &state.settings.Capacity,
+		&state.settings.OpensAt, &state.settings.ClosesAt, &state.settings.Waitlist, &state.students,
+	)
+	if err == sql.ErrNoRows {
+		return state, ErrNotFound
+	}
+
+	return state, err
+}
+
+// full reports whether every seat of the course is taken.
+func (s enrollmentState) full() bool {
+	return s.settings.Capacity != nil && s.students >= *s.settings.Capacity
+}
+
+// requestStatus returns the status of the user's enrollment request, or "" without one.
+func requestStatus(ctx context.Context, tx *sql.Tx, courseID int64, userID int64) (string, error) {
+	var status string
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT status FROM enrollments WHERE course_id = ? AND user_id = ? FOR UPDATE
+	`, courseID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return status, err
+}
+
+// setRequest stores the user's enrollment request with the given status. A decidedBy of 0 leaves
+// the request undecided.
+func setRequest(ctx context.Context, tx *sql.Tx, courseID int64, userID int64, status string, decidedBy int64, reason string) error {
+	var decider interface{}
+	if decidedBy != 0 {
+		decider = decidedBy
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO enrollments(
+			course_id, user_id, status, reason, date_requested, date_decided, decided_by
+		) VALUE(?, ?, ?, ?, NOW(), IF(? IS NULL, NULL, NOW()), ?)
+		ON DUPLICATE KEY UPDATE
+			status = VALUES(status),
+			reason = VALUES(reason),
+			date_decided = VALUES(date_decided),
+			decided_by = VALUES(decided_by)
+	`, courseID, userID, status, reason, decider, decider)
+
+	return err
+}
+
+// admit makes the user a student of the course and settles their request.
+func admit(ctx context.Context, tx *sql.Tx, courseID int64, userID int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO students(
+			course_id, user_id, date_entered
+		) VALUE(?, ?, NOW())
+	`, courseID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE courses SET students_count = students_count + 1 WHERE id = ?`, courseID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE enrollments SET status = ? WHERE course_id = ? AND user_id = ?
+	`, EnrollmentApproved, courseID, userID)
+
+	return err
+}
+
+func isStudent(ctx context.Context, tx *sql.Tx, courseID int64, userID int64) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM students WHERE course_id = ? AND user_id = ?
+	`, courseID, userID).Scan(&count)
+
+	return count > 0, err
+}
+
+// Enter enrolls the user according to the course's enrollment settings. It returns EnrollmentEntered when
+// the user became a student, EnrollmentPending when a mentor has to approve the request first and
+// EnrollmentWaitlisted when the user waits for a free seat. Refusals are EnrollmentRefusal errors.
+func (m ModelCourse) Enter(ctx context.Context, courseID int64, userID int64) (string, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	state, err := lockEnrollmentState(ctx, tx, courseID)
+	if err != nil {
+		return "", err
+	}
+
+	switch state.status {
+	case CourseDraft:
+		return "", ErrEnrollmentNotPublished
+	case CourseArchived:
+		return "", ErrEnrollmentArchived
+	}
+
+	student, err := isStudent(ctx, tx, courseID, userID)
+	if err != nil {
+		return "", err
+	}
+	if student {
+		return "", ErrAlreadyEntered
+	}
+
+	request, err := requestStatus(ctx, tx, courseID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	switch request {
+	case EnrollmentPending, EnrollmentWaitlisted:
+		return request, nil
+	case EnrollmentRejected:
+		return "", ErrEnrollmentRejected
+	}
+
+	now := time.Now()
+	if state.settings.OpensAt != nil && now.Before(*state.settings.OpensAt) {
+		return "", ErrEnrollmentNotOpen
+	}
+	if state.settings.ClosesAt != nil && !now.Before(*state.settings.ClosesAt) {
+		return "", ErrEnrollmentClosed
+	}
+
+	// An invitation stands in for the approval.
+	if request != EnrollmentInvited {
+		switch state.settings.Mode {
+		case EnrollmentInviteOnly:
+			return "", ErrEnrollmentInviteOnly
+		case EnrollmentApproval:
+			if err = setRequest(ctx, tx, courseID, userID, EnrollmentPending, 0, ""); err != nil {
+				return "", err
+			}
+			return EnrollmentPending, tx.Commit()
+		}
+	}
+
+	if state.full() {
+		if !state.settings.Waitlist {
+			return "", ErrEnrollmentFull
+		}
+		if err = setRequest(ctx, tx, courseID, userID, EnrollmentWaitlisted, 0, ""); err != nil {
+			return "", err
+		}
+		return EnrollmentWaitlisted, tx.Commit()
+	}
+
+	if err = admit(ctx, tx, courseID, userID); err != nil {
+		return "", err
+	}
+
+	return EnrollmentEntered, tx.Commit()
+}
+
+// Leave removes the user from the course's students and hands the freed seat to the first user on the
+// waitlist. A user who hasn't entered yet withdraws their pending or waitlisted request instead.
+func (m ModelCourse) Leave(ctx context.Context, courseID int64, userID int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = leaveCourse(ctx, tx, courseID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// leaveCourse does the work of Leave in the caller's transaction.
+func leaveCourse(ctx context.Context, tx *sql.Tx, courseID int64, userID int64) error {
+	state, err := lockEnrollmentState(ctx, tx, courseID)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM students WHERE course_id = ? AND user_id = ?
+	`, courseID, userID)
+	if err != nil {
+		return err
+	}
+
+	left, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM enrollments WHERE course_id = ? AND user_id = ? AND status <> ?
+	`, courseID, userID, EnrollmentRejected)
+	if err != nil {
+		return err
+	}
+
+	if left == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE courses SET students_count = students_count - 1 WHERE id = ?`, courseID)
+	if err != nil {
+		return err
+	}
+	state.students--
+
+	return admitWaitlisted(ctx, tx, courseID, state)
+}
+
+// admitWaitlisted hands the free seats of a published course to the users on its waitlist, in the
+// order they joined it.
+func admitWaitlisted(ctx context.Context, tx *sql.Tx, courseID int64, state enrollmentState) error {
+	if state.status != CoursePublished {
+		return nil
+	}
+
+	for !state.full() {
+		var next int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT user_id FROM enrollments
+			WHERE course_id = ? AND status = ?
+			ORDER BY date_requested, user_id
+			LIMIT 1
+			FOR UPDATE
+		`, courseID, EnrollmentWaitlisted).Scan(&next)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = admit(ctx, tx, courseID, next); err != nil {
+			return err
+		}
+		state.students++
+	}
+
+	return nil
+}
+
+// GetEnrollments returns the course's enrollment requests, oldest first, optionally only those with
+// the given status.
+func (m ModelCourse) GetEnrollments(ctx context.Context, courseID int64, status string) ([]Enrollment, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			e.course_id, u.id, u.user_name, u.full_name, u.avatar, u.about,
+			e.status, e.reason, e.date_requested, e.date_decided
+		FROM enrollments e
+		JOIN users u ON u.id = e.user_id
+		WHERE e.course_id = ? AND (? = '' OR e.status = ?)
+		ORDER BY e.date_requested, e.user_id
+	`, courseID, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]Enrollment, 0)
+	for rows.Next() {
+		var e Enrollment
+		err = rows.Scan(
+			&e.CourseID, &e.User.ID, &e.User.Name, &e.User.FullName, &e.User.Avatar, &e.User.About,
+			&e.Status, &e.Reason, &e.DateRequested, &e.DateDecided,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+
+	return list, rows.Err()
+}
+
+// InviteStudent lets the user enter the course without approval, also when it is invite-only.
+func (m ModelCourse) InviteStudent(ctx context.Context, courseID int64, userID int64, invitedBy int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var users int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE id = ?`, userID).Scan(&users); err != nil {
+		return err
+	}
+	if users == 0 {
+		return ValidationError(fmt.Sprintf("user %d does not exist", userID))
+	}
+
+	student, err := isStudent(ctx, tx, courseID, userID)
+	if err != nil {
+		return err
+	}
+	if student {
+		return ErrAlreadyEntered
+	}
+
+	if err = setRequest(ctx, tx, courseID, userID, EnrollmentInvited, invitedBy, ""); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Approve admits a pending or waitlisted user. When the course is full a pending request moves to the
+// waitlist if the course has one, and the result is EnrollmentWaitlisted.
+func (m ModelCourse) Approve(ctx context.Context, courseID int64, userID int64, decidedBy int64) (string, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	state, err := lockEnrollmentState(ctx, tx, courseID)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := requestStatus(ctx, tx, courseID, userID)
+	if err != nil {
+		return "", err
+	}
+	if request != EnrollmentPending && request != EnrollmentWaitlisted {
+		return "", ErrNotFound
+	}
+
+	if state.full() {
+		if request == EnrollmentWaitlisted || !state.settings.Waitlist {
+			return "", ErrEnrollmentFull
+		}
+		if err = setRequest(ctx, tx, courseID, userID, EnrollmentWaitlisted, decidedBy, ""); err != nil {
+			return "", err
+		}
+		return EnrollmentWaitlisted, tx.Commit()
+	}
+
+	if err = setRequest(ctx, tx, courseID, userID, EnrollmentApproved, decidedBy, ""); err != nil {
+		return "", err
+	}
+	if err = admit(ctx, tx, courseID, userID); err != nil {
+		return "", err
+	}
+
+	return EnrollmentEntered, tx.Commit()
+}
+
+// Reject turns down a pending, waitlisted or invited user. They can't request to enter again.
+func (m ModelCourse) Reject(ctx context.Context, courseID int64, userID int64, decidedBy int64, reason string) error {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE enrollments SET
+			status = ?,
+			reason = ?,
+			date_decided = NOW(),
+			decided_by = ?
+		WHERE course_id = ? AND user_id = ? AND status IN (?, ?, ?)
+	`, EnrollmentRejected, reason, decidedBy, courseID, userID, EnrollmentPending, EnrollmentWaitlisted, EnrollmentInvited)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
@@ -0,0 +1,20 @@
+package models
+
+import "errors"
+
+// ErrNotFound is returned when the requested row doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// ValidationError is returned when the input is well-formed JSON but its values are not acceptable.
+type ValidationError string
+
+func (e ValidationError) Error() string {
+	return string(e)
+}
+
+// ConflictError is returned when the request can't be applied to the current state of the data.
+type ConflictError string
+
+func (e ConflictError) Error() string {
+	return string(e)
+}
@@ -0,0 +1,285 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kinds of stored files, which are also the first part of their storage keys.
+const (
+	FileKindImage = "images"
+	FileKindFile  = "files"
+)
+
+// ErrFileNotOwned is returned when a file referenced by id was uploaded by someone else.
+var ErrFileNotOwned error = ValidationError("the file was uploaded by another user")
+
+// StoredFile is the metadata of a blob kept in the storage.
+type StoredFile struct {
+	ID           int64     `json:"id"`
+	Key          string    `json:"key"`
+	Kind         string    `json:"kind"`
+	OwnerID      *int64    `json:"owner_id"`
+	OriginalName string    `json:"original_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Checksum     string    `json:"checksum"`
+	URL          string    `json:"url"`
+	DateCreated  time.Time `json:"date_created"`
+
+	// ParentID is the image a thumbnail was rendered from. Images have their dimensions set.
+	ParentID *int64 `json:"parent_id"`
+	Width    *int   `json:"width"`
+	Height   *int   `json:"height"`
+}
+
+// SubmissionUse is a submission that hands in a stored file, which only its author and the course's
+// mentors may download.
+type SubmissionUse struct {
+	UserID   int64
+	CourseID int64
+}
+
+type ModelFile struct {
+	model
+}
+
+type IFileCreator interface {
+	Create(ctx context.Context, file StoredFile) (int64, error)
+	Get(ctx context.Context, id int64) (StoredFile, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type IFileServer interface {
+	GetSubmissionUses(ctx context.Context, key string) ([]SubmissionUse, error)
+}
+
+type IFileCollector interface {
+	GetOrphans(ctx context.Context, grace time.Duration, limit int) ([]StoredFile, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+func NewFileModel(db *sql.DB) ModelFile {
+	return ModelFile{model{db}}
+}
+
+func (m ModelFile) Create(ctx context.Context, file StoredFile) (int64, error) {
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO files(
+			storage_key, kind, owner_id, original_name, content_type, size, checksum, url, date_created,
+			parent_id, width, height
+		) VALUE(?, ?, ?, ?, ?, ?, ?, ?, NOW(), ?, ?, ?)
+	`,
+		file.Key, file.Kind, file.OwnerID, file.OriginalName, file.ContentType, file.Size, file.Checksum, file.URL,
+		file.ParentID, file.Width, file.Height,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+func (m ModelFile) Get(ctx context.Context, id int64) (StoredFile, error) {
+	file := StoredFile{}
+
+	err := m.db.QueryRowContext(ctx, `
+		SELECT
+			id, storage_key, kind, owner_id, original_name, content_type, size, checksum, url, date_created,
+			parent_id, width, height
+		FROM files
+		WHERE id = ?
+	`, id).Scan(
+		&file.ID, &file.Key, &file.Kind, &file.OwnerID, &file.OriginalName, &file.ContentType,
+		&file.Size, &file.Checksum, &file.URL, &file.DateCreated,
+		&file.ParentID, &file.Width, &file.Height,
+	)
+	if err == sql.ErrNoRows {
+		return StoredFile{}, ErrNotFound
+	}
+	if err != nil {
+		return StoredFile{}, err
+	}
+
+	return file, nil
+}
+
+// fileURL returns the public URL of the stored file with the given id. A non-zero ownerID requires
+// the file to be one of that user's uploads.
+func fileURL(ctx context.Context, q rowQuerier, id int64, ownerID int64) (string, error) {
+	var link string
+	var owner *int64
+
+	err := q.QueryRowContext(ctx, `SELECT url, owner_id FROM files WHERE id = ?`, id).Scan(&link, &owner)
+	if err == sql.ErrNoRows {
+		return "", ValidationError(fmt.Sprintf("file %d does not exist", id))
+	}
+	if err != nil {
+		return "", err
+	}
+	if ownerID != 0 && (owner == nil || *owner != ownerID) {
+		return "", ErrFileNotOwned
+	}
+
+	return link, nil
+}
+
+// Columns of file_references naming the row that uses a file.
+const (
+	refCourse     = "course_id"
+	refUser       = "user_id"
+	refComponent  = "component_id"
+	refSubmission = "submission_id"
+	refAssignment = "assignment_id"
+	refQuiz       = "quiz_id"
+)
+
+var textURLPattern = regexp.MustCompile(`https?://[^\s"'()<>\[\]]+`)
+
+// textURLs returns the links written into texts such as descriptions and markdown, so that the
+// files they show are kept.
+func textURLs(texts ...string) []string {
+	urls := make([]string, 0)
+	for _, text := range texts {
+		urls = append(urls, textURLPattern.FindAllString(text, -1)...)
+	}
+
+	return urls
+}
+
+// fileKeyOf returns the storage key a file URL points at, which is the kind and the name at the end
+// of its path, or "" when it doesn't look like a stored file. Keys rather than whole URLs are
+// matched, so references survive a change of the public base URL.
+func fileKeyOf(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimRight(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	kind, name := parts[len(parts)-2], parts[len(parts)-1]
+	if (kind != FileKindImage && kind != FileKindFile) || name == "" {
+		return ""
+	}
+
+	return kind + "/" + name
+}
+
+// syncFileRefs replaces the files the row of the given file_references column uses with the stored
+// files among urls. URLs of other sites are ignored.
+func syncFileRefs(ctx context.Context, q execQuerier, column string, rowID int64, urls ...string) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM file_references WHERE `+column+` = ?`, rowID); err != nil {
+		return err
+	}
+
+	keys := make([]interface{}, 0, len(urls))
+	for _, raw := range urls {
+		if key := fileKeyOf(raw); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	in := "(?" + strings.Repeat(", ?", len(keys)-1) + ")"
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO file_references(file_id, `+column+`)
+		SELECT id, ? FROM files WHERE storage_key IN `+in+`
+	`, append([]interface{}{rowID}, keys...)...)
+
+	return err
+}
+
+// GetOrphans returns up to limit files created more than grace ago that nothing uses, together with
+// their thumbnails. An image counts as used when it or one of its thumbnails is referenced. Thumbnails
+// come before the images they were rendered from, so that deleting in order never leaves one behind.
+func (m ModelFile) GetOrphans(ctx context.Context, grace time.Duration, limit int) ([]StoredFile, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			id, storage_key, kind, owner_id, original_name, content_type, size, checksum, url, date_created,
+			parent_id, width, height
+		FROM files
+		WHERE COALESCE(parent_id, id) IN (
+			SELECT f.id
+			FROM files f
+			WHERE f.parent_id IS NULL
+				AND f.date_created <= NOW() - INTERVAL ? SECOND
+				AND NOT EXISTS (SELECT 1 FROM file_references fr WHERE fr.file_id = f.id)
+				AND NOT EXISTS (
+					SELECT 1 FROM file_references fr JOIN files v ON v.id = fr.file_id
+					WHERE v.parent_id = f.id
+				)
+		)
+		ORDER BY parent_id IS NULL, id
+		LIMIT ?
+	`, int64(grace/time.Second), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]StoredFile, 0)
+	for rows.Next() {
+		var file StoredFile
+		err = rows.Scan(
+			&file.ID, &file.Key, &file.Kind, &file.OwnerID, &file.OriginalName, &file.ContentType,
+			&file.Size, &file.Checksum, &file.URL, &file.DateCreated,
+			&file.ParentID, &file.Width, &file.Height,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, file)
+	}
+
+	return list, rows.Err()
+}
+
+// GetSubmissionUses returns the submissions that hand in the file stored under key, or one of the
+// images a thumbnail under key was rendered from.
+func (m ModelFile) GetSubmissionUses(ctx context.Context, key string) ([]SubmissionUse, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT s.user_id, l.course_id
+		FROM files f
+		JOIN file_references fr ON fr.file_id IN (f.id, f.parent_id)
+		JOIN submissions s ON s.id = fr.submission_id
+		JOIN assignments a ON a.id = s.assignment_id
+		JOIN lessons l ON l.id = a.lesson_id
+		WHERE f.storage_key = ?
+	`, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uses := make([]SubmissionUse, 0)
+	for rows.Next() {
+		var use SubmissionUse
+		if err = rows.Scan(&use.UserID, &use.CourseID); err != nil {
+			return nil, err
+		}
+		uses = append(uses, use)
+	}
+
+	return uses, rows.Err()
+}
+
+// Delete removes the metadata of a file. The caller deletes the blob from the storage first.
+func (m ModelFile) Delete(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM files WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationRevoked  = "revoked"
+
+	// InvitationExpired is never stored: a pending invitation reads as expired once expires_at has passed.
+	InvitationExpired = "expired"
+)
+
+// InvitationTTL is how long an invitation can be accepted after it was sent.
+const InvitationTTL = 14 * 24 * time.Hour
+
+var (
+	ErrAlreadyMentor      error = ConflictError("the user is already a mentor of the course")
+	ErrAlreadyInvited     error = ConflictError("the user already has a pending invitation to the course")
+	ErrInvitationAnswered error = ConflictError("the invitation is no longer pending")
+)
+
+type Invitation struct {
+	ID          int64      `json:"id"`
+	CourseID    int64      `json:"course_id"`
+	CourseTitle string     `json:"course_title"`
+	UserID      int64      `json:"user_id"`
+	InvitedBy   int64      `json:"invited_by"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	DateCreated time.Time  `json:"date_created"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+type ModelInvitation struct {
+	model
+}
+
+type IInvitationGetter interface {
+	Get(ctx context.Context, id int64) (Invitation, error)
+}
+
+type IInvitationEditor interface {
+	GetCourseList(ctx context.Context, courseID int64) ([]Invitation, error)
+	Invite(ctx context.Context, courseID int64, invitedBy int64, in MentorInput) (int64, error)
+	Revoke(ctx context.Context, courseID int64, id int64) error
+	IInvitationGetter
+}
+
+type IInvitationResponder interface {
+	GetUserList(ctx context.Context, userID int64) ([]Invitation, error)
+	Accept(ctx context.Context, id int64, userID int64) error
+	Decline(ctx context.Context, id int64, userID int64) error
+	IInvitationGetter
+}
+
+func NewInvitationModel(db *sql.DB) ModelInvitation {
+	return ModelInvitation{model{db}}
+}
+
+const selectInvitations = `
+	SELECT
+		i.id, i.course_id, c.title, i.user_id, i.invited_by, i.role,
+		CASE WHEN i.status = 'pending' AND i.expires_at <= NOW() THEN 'expired' ELSE i.status END,
+		i.date_created, i.expires_at, i.responded_at
+	FROM mentor_invitations i
+	JOIN courses c ON c.id = i.course_id
+`
+
+func scanInvitations(rows *sql.Rows) ([]Invitation, error) {
+	defer rows.Close()
+
+	list := make([]Invitation, 0)
+	for rows.Next() {
+		inv := Invitation{}
+		err := rows.Scan(
+			&inv.ID, &inv.CourseID, &inv.CourseTitle, &inv.UserID, &inv.InvitedBy, &inv.Role,
+			&inv.Status, &inv.DateCreated, &inv.ExpiresAt, &inv.RespondedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+
+	return list, rows.Err()
+}
+
+// inviteMentor stores a pending invitation for the user, unless they are already a mentor of the course
+// or have an invitation there that is still pending. The caller validates the user and role.
+func inviteMentor(ctx context.Context, tx *sql.Tx, courseID int64, invitedBy int64, userID int64, role string) (int64, error) {
+	var mentors, pending int
+	err := tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM mentors WHERE course_id = ? AND user_id = ?),
+			(SELECT COUNT(*) FROM mentor_invitations
+			 WHERE course_id = ? AND user_id = ? AND status = 'pending' AND expires_at > NOW())
+	`, courseID, userID, courseID, userID).Scan(&mentors, &pending)
+	if err != nil {
+		return 0, err
+	}
+	if mentors > 0 {
+		return 0, ErrAlreadyMentor
+	}
+	if pending > 0 {
+		return 0, ErrAlreadyInvited
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO mentor_invitations(
+			course_id, user_id, invited_by, role, status, date_created, expires_at
+		) VALUE(?, ?, ?, ?, ?, NOW(), NOW() + INTERVAL ? SECOND)
+	`, courseID, userID, invitedBy, role, InvitationPending, int64(InvitationTTL/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+func (m ModelInvitation) Get(ctx context.Context, id int64) (Invitation, error) {
+	rows, err := m.db.QueryContext(ctx, selectInvitations+`WHERE i.id = ?`, id)
+	if err != nil {
+		return Invitation{}, err
+	}
+
+	list, err := scanInvitations(rows)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if len(list) == 0 {
+		return Invitation{}, ErrNotFound
+	}
+
+	return list[0], nil
+}
+
+// GetCourseList returns every invitation sent for the course, newest first.
+func (m ModelInvitation) GetCourseList(ctx context.Context, courseID int64) ([]Invitation, error) {
+	rows, err := m.db.QueryContext(ctx, selectInvitations+`WHERE i.course_id = ? ORDER BY i.id DESC`, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanInvitations(rows)
+}
+
+// GetUserList returns the invitations the user can still accept or decline.
+func (m ModelInvitation) GetUserList(ctx context.Context, userID int64) ([]Invitation, error) {
+	rows, err := m.db.QueryContext(ctx, selectInvitations+`
+		WHERE i.user_id = ? AND i.status = 'pending' AND i.expires_at > NOW()
+		ORDER BY i.id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanInvitations(rows)
+}
+
+// validateInvitee checks that the user can mentor the course as it is now: they exist, don't own it
+// and don't study it. The course row stays locked, so that it can't change hands before the
+// transaction ends.
+func validateInvitee(ctx context.Context, tx *sql.Tx, courseID int64, userID int64, role string) error {
+	var ownerID int64
+	err := tx.QueryRowContext(ctx, `SELECT owner_id FROM courses WHERE id = ? FOR UPDATE`, courseID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = validateMentors(ctx, tx, ownerID, map[int64]string{userID: role}); err != nil {
+		return err
+	}
+
+	student, err := isStudent(ctx, tx, courseID, userID)
+	if err != nil {
+		return err
+	}
+	if student {
+		return ValidationError("a student of the course can't be a mentor of the same course")
+	}
+
+	return nil
+}
+
+// Invite sends the user an invitation to mentor the course with the given role.
+func (m ModelInvitation) Invite(ctx context.Context, courseID int64, invitedBy int64, in MentorInput) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err = validateInvitee(ctx, tx, courseID, in.UserID, in.Role); err != nil {
+		return 0, err
+	}
+
+	id, err := inviteMentor(ctx, tx, courseID, invitedBy, in.UserID, in.Role)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// Revoke withdraws a pending invitation of the course. Answered or expired invitations can't be revoked.
+func (m ModelInvitation) Revoke(ctx context.Context, courseID int64, id int64) error {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE mentor_invitations SET
+			status = ?,
+			responded_at = NOW()
+		WHERE id = ? AND course_id = ? AND status = ? AND expires_at > NOW()
+	`, InvitationRevoked, id, courseID, InvitationPending)
+	if err != nil {
+		return err
+	}
+
+	return m.requirePending(ctx, res, id, courseID, 0)
+}
+
+// Accept makes the invited user a mentor of the course with the invitation's role, unless they own
+// or study the course by now.
+func (m ModelInvitation) Accept(ctx context.Context, id int64, userID int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var courseID int64
+	var role string
+	err = tx.QueryRowContext(ctx, `
+		SELECT course_id, role FROM mentor_invitations
+		WHERE id = ? AND user_id = ? AND status = ? AND expires_at > NOW()
+		FOR UPDATE
+	`, id, userID, InvitationPending).Scan(&courseID, &role)
+	if err == sql.ErrNoRows {
+		return m.requirePending(ctx, nil, id, 0, userID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The user may have been handed the course or entered it since they were invited.
+	if err = validateInvitee(ctx, tx, courseID, userID, role); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO mentors(course_id, user_id, role) VALUE(?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = VALUES(role)
+	`, courseID, userID, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE mentor_invitations SET status = ?, responded_at = NOW() WHERE id = ?
+	`, InvitationAccepted, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ModelInvitation) Decline(ctx context.Context, id int64, userID int64) error {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE mentor_invitations SET
+			status = ?,
+			responded_at = NOW()
+		WHERE id = ? AND user_id = ? AND status = ? AND expires_at > NOW()
+	`, InvitationDeclined, id, userID, InvitationPending)
+	if err != nil {
+		return err
+	}
+
+	return m.requirePending(ctx, res, id, 0, userID)
+}
+
+// requirePending tells apart why a pending-only statement matched nothing: ErrNotFound when the invitation
+// doesn't exist for that course or user, ErrInvitationAnswered when it does but is no longer pending.
+// A non-zero courseID or userID restricts the lookup to it.
+func (m ModelInvitation) requirePending(ctx context.Context, res sql.Result, id int64, courseID int64, userID int64) error {
+	if res != nil {
+		if err := requireAffected(res); err != ErrNotFound {
+			return err
+		}
+	}
+
+	inv, err := m.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if (courseID != 0 && inv.CourseID != courseID) || (userID != 0 && inv.UserID != userID) {
+		return ErrNotFound
+	}
+
+	return ErrInvitationAnswered
+}
@@ -1,6 +1,9 @@
 package models
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 type Lesson struct {
 	ID          int    `json:"id"`
@@ -10,6 +13,8 @@ type Lesson struct {
 	Description string `json:"description"`
 	Image       []byte `json:"image"`
 	CourseID    int    `json:"course_id"`
+
+	Components []Component `json:"components,omitempty"`
 }
 
 type LessonCreateInput struct {
@@ -25,115 +30,193 @@ type ModelLesson struct {
 }
 
 type ILessonLister interface {
-	GetList(courseID int64, limit, offset int) []Lesson
+	GetList(ctx context.Context, courseID int64, limit, offset int) ([]Lesson, error)
+	Count(ctx context.Context, courseID int64) (int, error)
 }
 
 type ILessonGetter interface {
-	Get(id int64) Lesson
+	Get(ctx context.Context, id int64) (Lesson, error)
 }
 
 type ILessonCreator interface {
-	Create(in LessonCreateInput) int64
+	Create(ctx context.Context, in LessonCreateInput) (int64, error)
 	ILessonGetter
 }
 
 type ILessonDeleter interface {
-	Delete(id int64)
+	Delete(ctx context.Context, id int64) error
+	ILessonGetter
 }
 
 type ILessonUpdater interface {
-	Update(in Lesson)
+	Update(ctx context.Context, in Lesson) error
 	ILessonGetter
 }
 
+type ILessonReorderer interface {
+	Reorder(ctx context.Context, courseID int64, lessonIDs []int64) error
+	ILessonLister
+}
+
 func NewLessonModel(db *sql.DB) ModelLesson {
 	return ModelLesson{model{db}}
 }
 
-func (m ModelLesson) GetList(courseID int64, limit, offset int) []Lesson {
+func (m ModelLesson) GetList(ctx context.Context, courseID int64, limit, offset int) ([]Lesson, error) {
 	lessons := make([]Lesson, 0)
 
-	rows, err := m.db.Query(`SELECT * FROM lessons LIMIT ? OFFSET ?`, limit, offset)
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			id, title, theme, description, number, header_ava, course_id
+		FROM lessons
+		WHERE course_id = ?
+		ORDER BY number
+		LIMIT ? OFFSET ?
+	`, courseID, limit, offset)
 	if err != nil {
-		return lessons
+		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var lesson Lesson
 
-		err = rows.Scan(&lesson.ID, &lesson.Title, &lesson.Theme, &lesson.Number, &lesson.Image, &lesson.CourseID)
+		err = rows.Scan(&lesson.ID, &lesson.Title, &lesson.Theme, &lesson.Description, &lesson.Number, &lesson.Image, &lesson.CourseID)
 		if err != nil {
-			//
-			return lessons
+			return nil, err
 		}
 
-		// TODO: select lesson components?
-
 		lessons = append(lessons, lesson)
 	}
 
 	if err = rows.Err(); err != nil {
-		// log error
+		return nil, err
+	}
+
+	return lessons, nil
+}
+
+func (m ModelLesson) Count(ctx context.Context, courseID int64) (int, error) {
+	var count int
+
+	row := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM lessons WHERE course_id = ?`, courseID)
+	if err := row.Scan(&count); err != nil {
+		return 0, err
 	}
 
-	return lessons
+	return count, nil
 }
 
-func (m ModelLesson) Get(id int64) Lesson {
+func (m ModelLesson) Get(ctx context.Context, id int64) (Lesson, error) {
 	lesson := Lesson{}
 
-	row := m.db.QueryRow(`SELECT id, title, theme, description, number, header_ava, course_id FROM lessons WHERE id = ?`, id)
+	row := m.db.QueryRowContext(ctx, `SELECT id, title, theme, description, number, header_ava, course_id FROM lessons WHERE id = ?`, id)
 	err := row.Scan(&lesson.ID, &lesson.Title, &lesson.Theme, &lesson.Description, &lesson.Number, &lesson.Image, &lesson.CourseID)
+	if err == sql.ErrNoRows {
+		return Lesson{}, ErrNotFound
+	}
 	if err != nil {
-		return Lesson{}
+		return Lesson{}, err
 	}
 
-	// TODO: select lessons components?
+	if lesson.Components, err = selectComponents(ctx, m.db, id); err != nil {
+		return Lesson{}, err
+	}
 
-	return lesson
+	return lesson, nil
 }
 
-func (m ModelLesson) Create(in LessonCreateInput) int64 {
-	// TODO: define lesson number
-	stmt, err := m.db.Prepare(`INSERT INTO lessons(number, title, theme, description, header_ava, course_id) VALUE(1, ?, ?, ?, ?, ?)`)
+// Create appends the lesson to the end of its course, numbering it after the last existing lesson.
+// The course row is locked first, so that concurrent creates in the same course take turns. The
+// progress of the course's students is recomputed with the new lesson.
+func (m ModelLesson) Create(ctx context.Context, in LessonCreateInput) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var courseID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM courses WHERE id = ? FOR UPDATE`, in.CourseID).Scan(&courseID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	res, err := stmt.Exec(in.Title, in.Theme, in.Description, []byte(in.Image), in.CourseID)
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO lessons(number, title, theme, description, header_ava, course_id)
+		SELECT COALESCE(MAX(number), 0) + 1, ?, ?, ?, ?, ?
+		FROM lessons
+		WHERE course_id = ?
+	`, in.Title, in.Theme, in.Description, []byte(in.Image), courseID, courseID)
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	lastID, err := res.LastInsertId()
+	id, err := res.LastInsertId()
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	return lastID
+	if err = recomputeStudentsProgress(ctx, tx, courseID); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (m ModelLesson) Delete(id int64) {
-	_, err := m.db.Exec(`DELETE FROM lessons WHERE id = ?`, id)
+// Delete removes the lesson and shifts the following lessons of the course up to close the gap. The
+// progress of the course's students is recomputed without it.
+func (m ModelLesson) Delete(ctx context.Context, id int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	courseID, err := deleteNumbered(ctx, tx, "lessons", "course_id", id)
 	if err != nil {
-		return
+		return err
+	}
+
+	if err = recomputeStudentsProgress(ctx, tx, courseID); err != nil {
+		return err
 	}
+
+	return tx.Commit()
 }
 
-func (m ModelLesson) Update(in Lesson) {
-	stmt, err := m.db.Prepare(`
+func (m ModelLesson) Update(ctx context.Context, in Lesson) error {
+	stmt, err := m.db.PrepareContext(ctx, `
 		UPDATE lessons SET
 			title = ?,
 			theme  = ?,
 			description  = ?,
-		    header_ava = ?,
-		    course_id = ?,
-		    number = ?
+		    header_ava = ?
 		WHERE id = ?`)
 	if err != nil {
-		return
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, in.Title, in.Theme, in.Description, in.Image, in.ID)
+
+	return err
+}
+
+// Reorder renumbers the course's lessons in the order of lessonIDs, which must contain each of them once.
+func (m ModelLesson) Reorder(ctx context.Context, courseID int64, lessonIDs []int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = renumber(ctx, tx, "lessons", "course_id", courseID, lessonIDs); err != nil {
+		return err
 	}
 
-	_, err = stmt.Exec(in.Title, in.Theme, in.Description, in.Image, in.CourseID, in.Number, in.ID)
+	return tx.Commit()
 }
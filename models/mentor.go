@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type MentorInput struct {
+	UserID int64  `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+}
+
+type ICourseMentorEditor interface {
+	RemoveMentor(ctx context.Context, courseID int64, userID int64) error
+	GetMentorsList(ctx context.Context, courseID int64) ([]Mentor, error)
+}
+
+func isMentorRole(role string) bool {
+	return role == MentorRoleTeacher || role == MentorRoleAssistant
+}
+
+// validateMentors checks the roles and that every mentor is an existing user other than the owner.
+func validateMentors(ctx context.Context, tx *sql.Tx, ownerID int64, mentors map[int64]string) error {
+	if len(mentors) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(mentors))
+	for id, role := range mentors {
+		if !isMentorRole(role) {
+			return ValidationError(fmt.Sprintf("mentor role must be %q or %q, got %q", MentorRoleTeacher, MentorRoleAssistant, role))
+		}
+		if id == ownerID {
+			return ValidationError("the course owner can't be a mentor of the same course")
+		}
+		ids = append(ids, id)
+	}
+
+	in, args := inClause(ids)
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM users WHERE id IN `+in, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := make(map[int64]bool, len(ids))
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return err
+		}
+		found[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if !found[id] {
+			return ValidationError(fmt.Sprintf("mentor user %d does not exist", id))
+		}
+	}
+
+	return nil
+}
+
+// syncMentors makes the mentors of the course match the given list: it deletes mentors missing from it
+// and updates changed roles, leaving unchanged rows alone. Users who aren't mentors yet are invited on
+// behalf of the owner instead of being added, unless an invitation to them is already pending.
+func syncMentors(ctx context.Context, tx *sql.Tx, courseID int64, ownerID int64, list []Mentor) error {
+	wanted := make(map[int64]string, len(list))
+	for _, mentor := range list {
+		wanted[int64(mentor.ID)] = mentor.Role
+	}
+
+	if err := validateMentors(ctx, tx, ownerID, wanted); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id, role FROM mentors WHERE course_id = ? FOR UPDATE`, courseID)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[int64]string)
+	for rows.Next() {
+		var userID int64
+		var role string
+		if err = rows.Scan(&userID, &role); err != nil {
+			rows.Close()
+			return err
+		}
+		current[userID] = role
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for userID := range current {
+		if _, ok := wanted[userID]; !ok {
+			_, err = tx.ExecContext(ctx, `DELETE FROM mentors WHERE course_id = ? AND user_id = ?`, courseID, userID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for userID, role := range wanted {
+		currentRole, ok := current[userID]
+		switch {
+		case !ok:
+			if _, err = inviteMentor(ctx, tx, courseID, ownerID, userID, role); err == ErrAlreadyInvited {
+				err = nil
+			}
+		case currentRole != role:
+			_, err = tx.ExecContext(ctx, `UPDATE mentors SET role = ? WHERE course_id = ? AND user_id = ?`, role, courseID, userID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveMentor removes the user from the course's mentors, or returns ErrNotFound if they aren't one.
+func (m ModelCourse) RemoveMentor(ctx context.Context, courseID int64, userID int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM mentors WHERE course_id = ? AND user_id = ?`, courseID, userID)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+var ErrOrderMismatch error = ValidationError("ids must list every item exactly once")
+
+// renumber sets table.number to the position of each id in ids for all rows whose parentColumn equals parentID.
+// ids must contain every such row exactly once, otherwise ErrOrderMismatch is returned and nothing changes.
+// It must be called inside a transaction; table and parentColumn are never user input.
+func renumber(ctx context.Context, tx *sql.Tx, table, parentColumn string, parentID int64, ids []int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM `+table+` WHERE `+parentColumn+` = ? FOR UPDATE`, parentID)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[id] = false
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ids) != len(existing) {
+		return ErrOrderMismatch
+	}
+	for _, id := range ids {
+		seen, ok := existing[id]
+		if !ok || seen {
+			return ErrOrderMismatch
+		}
+		existing[id] = true
+	}
+
+	// Move every row out of the way first so the new numbers never collide with the old ones.
+	if _, err = tx.ExecContext(ctx, `UPDATE `+table+` SET number = -number WHERE `+parentColumn+` = ?`, parentID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE `+table+` SET number = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, id := range ids {
+		if _, err = stmt.ExecContext(ctx, i+1, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteNumbered deletes the row with the given id and shifts the following rows of the same parent up by one.
+// It returns the id of the parent and must be called inside a transaction.
+func deleteNumbered(ctx context.Context, tx *sql.Tx, table, parentColumn string, id int64) (int64, error) {
+	var parentID, number int64
+	row := tx.QueryRowContext(ctx, `SELECT `+parentColumn+`, number FROM `+table+` WHERE id = ? FOR UPDATE`, id)
+	err := row.Scan(&parentID, &number)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE `+table+` SET number = number - 1
+		WHERE `+parentColumn+` = ? AND number > ?
+		ORDER BY number
+	`, parentID, number)
+	if err != nil {
+		return 0, err
+	}
+
+	return parentID, nil
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MaxPageLimit caps the number of rows a single list request returns.
+const MaxPageLimit = 100
+
+// SortRelevance orders search results by how well they match. It can't be paged with cursors.
+const SortRelevance = "relevance"
+
+var ErrInvalidCursor error = ValidationError("invalid cursor")
+
+// PageRequest selects a page of a list. A non-empty Cursor takes precedence over Offset.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Cursor string
+}
+
+// PageInfo holds the opaque cursors of the pages around the returned one, empty when there is none.
+type PageInfo struct {
+	Next string
+	Prev string
+}
+
+// sortKey is a column a list can be sorted by. The row id breaks ties so the order is stable.
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// cursor is the position after (or, for Before, ahead of) a row in a sorted list.
+type cursor struct {
+	Sort   string `json:"s"`
+	Value  string `json:"v"`
+	ID     int64  `json:"i"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	c := cursor{}
+	if err = json.Unmarshal(data, &c); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// sortNames lists the accepted sort names for error messages.
+func sortNames(keys map[string]sortKey) string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// keyset is the part of a query that selects and orders one page of a sorted list.
+type keyset struct {
+	req    PageRequest
+	key    sortKey
+	idCol  string
+	cursor *cursor
+}
+
+// newKeyset checks the sort and cursor of the request against the sort keys of a list. When the request
+// has no sort, fallback is used.
+func newKeyset(req PageRequest, keys map[string]sortKey, fallback string, idCol string) (keyset, error) {
+	if req.Sort == "" {
+		req.Sort = fallback
+	}
+
+	key, ok := keys[req.Sort]
+	if !ok {
+		return keyset{}, ValidationError(fmt.Sprintf("sort must be one of %s, got %q", sortNames(keys), req.Sort))
+	}
+
+	k := keyset{req: req, key: key, idCol: idCol}
+
+	if req.Cursor != "" {
+		c, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return keyset{}, err
+		}
+		if c.Sort != req.Sort {
+			return keyset{}, ValidationError(fmt.Sprintf("the cursor belongs to sort %q, not %q", c.Sort, req.Sort))
+		}
+		k.cursor = &c
+		k.req.Offset = 0
+	}
+
+	return k, nil
+}
+
+// backwards tells whether the rows are read in reverse to reach the page ahead of the cursor.
+func (k keyset) backwards() bool {
+	return k.cursor != nil && k.cursor.Before
+}
+
+// column is the expression that reads the sort value of a row as text, for building cursors.
+func (k keyset) column() string {
+	return "CAST(" + k.key.column + " AS CHAR)"
+}
+
+// where returns the condition keeping the rows past the cursor, or TRUE without one.
+func (k keyset) where() (string, []interface{}) {
+	if k.cursor == nil {
+		return "TRUE", nil
+	}
+
+	op := ">"
+	if k.key.desc != k.cursor.Before {
+		op = "<"
+	}
+
+	cond := fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", k.key.column, op, k.key.column, k.idCol, op)
+
+	return cond, []interface{}{k.cursor.Value, k.cursor.Value, k.cursor.ID}
+}
+
+// orderLimit returns the ORDER BY, LIMIT and OFFSET clauses. One row more than requested is read
+// to find out whether another page follows.
+func (k keyset) orderLimit() (string, []interface{}) {
+	dir := "ASC"
+	if k.key.desc != k.backwards() {
+		dir = "DESC"
+	}
+
+	clause := fmt.Sprintf("ORDER BY %s %s, %s %s LIMIT ? OFFSET ?", k.key.column, dir, k.idCol, dir)
+
+	return clause, []interface{}{k.req.Limit + 1, k.req.Offset}
+}
+
+// finish trims the extra row, puts a backwards page back in list order and builds the cursors around it.
+// list is the scanned slice, with the sort values and ids of its rows in values and ids.
+// It returns how many rows of list belong to the page.
+func (k keyset) finish(list interface{}, values []string, ids []int64) (int, PageInfo) {
+	n := len(ids)
+	more := n > k.req.Limit
+	if more {
+		n = k.req.Limit
+	}
+
+	if k.backwards() {
+		swap := reflect.Swapper(list)
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+			values[i], values[j] = values[j], values[i]
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+
+	info := PageInfo{}
+	if n == 0 {
+		return 0, info
+	}
+
+	first := cursor{Sort: k.req.Sort, Value: values[0], ID: ids[0], Before: true}
+	last := cursor{Sort: k.req.Sort, Value: values[n-1], ID: ids[n-1]}
+
+	// Going back, the extra row lies before the page; otherwise it lies after it.
+	if k.backwards() {
+		info.Next = last.encode()
+		if more {
+			info.Prev = first.encode()
+		}
+	} else {
+		if more {
+			info.Next = last.encode()
+		}
+		if k.cursor != nil || k.req.Offset > 0 {
+			info.Prev = first.encode()
+		}
+	}
+
+	return n, info
+}
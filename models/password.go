@@ -0,0 +1,39 @@
+package models
+
+import (
+	"crypto/subtle"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// HashPassword returns a bcrypt hash of the given plaintext password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// isBcryptHash reports whether stored looks like a bcrypt hash rather than a legacy plaintext password.
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") ||
+		strings.HasPrefix(stored, "$2b$") ||
+		strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword compares a plaintext password with the value stored in users.password_hash.
+// Legacy rows that still hold a plaintext password are compared in constant time,
+// and needsRehash is set so the caller can upgrade them to a bcrypt hash.
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if isBcryptHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil, false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false, false
+	}
+
+	return true, true
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AccessLevel is the relation of a user to a course. Higher levels include the lower ones.
+type AccessLevel int
+
+const (
+	LevelNone AccessLevel = iota
+	LevelStudent
+	LevelMentor
+	LevelOwner
+)
+
+func (l AccessLevel) String() string {
+	switch l {
+	case LevelStudent:
+		return "student"
+	case LevelMentor:
+		return "mentor"
+	case LevelOwner:
+		return "owner"
+	default:
+		return "none"
+	}
+}
+
+// Roles stored in mentors.role.
+const (
+	MentorRoleTeacher   = "teacher"
+	MentorRoleAssistant = "assistant"
+)
+
+// CourseAccess describes what a user is on a course.
+type CourseAccess struct {
+	CourseID int64       `json:"course_id"`
+	UserID   int64       `json:"user_id"`
+	Level    AccessLevel `json:"-"`
+	Role     string      `json:"role,omitempty"`
+	Status   string      `json:"status"`
+}
+
+// Visible reports whether the user may see the course at all: drafts are hidden from everyone but
+// the owner and mentors.
+func (a CourseAccess) Visible() bool {
+	return a.Status != CourseDraft || a.Level >= LevelMentor
+}
+
+// Permission is an action on a course that requires a minimal access level and, for mentors, a role.
+// Permissions that write are refused on archived courses.
+type Permission struct {
+	Name        string
+	Level       AccessLevel
+	MentorRoles []string // empty means any mentor role is enough
+	Writes      bool
+}
+
+var (
+	PermissionViewCourse    = Permission{Name: "view_course", Level: LevelNone}
+	PermissionViewLessons   = Permission{Name: "view_lessons", Level: LevelStudent}
+	PermissionParticipate   = Permission{Name: "participate", Level: LevelStudent, Writes: true}
+	PermissionEditLessons   = Permission{Name: "edit_lessons", Level: LevelMentor, Writes: true}
+	PermissionViewWork      = Permission{Name: "view_work", Level: LevelMentor}
+	PermissionEnrollments   = Permission{Name: "manage_enrollments", Level: LevelMentor, Writes: true}
+	PermissionEditCourse    = Permission{Name: "edit_course", Level: LevelMentor, MentorRoles: []string{MentorRoleTeacher}, Writes: true}
+	PermissionDeleteCourse  = Permission{Name: "delete_course", Level: LevelOwner}
+	PermissionManageMentors = Permission{Name: "manage_mentors", Level: LevelOwner, Writes: true}
+	PermissionChangeStatus  = Permission{Name: "change_status", Level: LevelOwner}
+)
+
+// Allows reports whether access grants the permission, and a human readable reason when it does not.
+func (a CourseAccess) Allows(p Permission) (bool, string) {
+	if a.Level < p.Level {
+		return false, "requires " + p.Level.String() + " access to the course, you are " + a.Level.String()
+	}
+
+	if p.Writes && a.Status == CourseArchived {
+		return false, "the course is archived and can't be changed"
+	}
+
+	if a.Level != LevelMentor || len(p.MentorRoles) == 0 {
+		return true, ""
+	}
+
+	for _, role := range p.MentorRoles {
+		if a.Role == role {
+			return true, ""
+		}
+	}
+
+	return false, "mentor role " + a.Role + " is not allowed to " + p.Name
+}
+
+type ICourseAccessGetter interface {
+	GetAccess(ctx context.Context, courseID int64, userID int64) (CourseAccess, error)
+}
+
+// GetAccess returns the user's access to the course, or ErrNotFound when the course doesn't exist.
+func (m ModelCourse) GetAccess(ctx context.Context, courseID int64, userID int64) (CourseAccess, error) {
+	access := CourseAccess{CourseID: courseID, UserID: userID}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			c.owner_id, c.status, m.role, s.user_id
+		FROM courses c
+		LEFT JOIN mentors m ON m.course_id = c.id AND m.user_id = ?
+		LEFT JOIN students s ON s.course_id = c.id AND s.user_id = ?
+		WHERE c.id = ?
+	`, userID, userID, courseID)
+
+	var ownerID int64
+	var role sql.NullString
+	var studentID sql.NullInt64
+
+	err := row.Scan(&ownerID, &access.Status, &role, &studentID)
+	if err == sql.ErrNoRows {
+		return access, ErrNotFound
+	}
+	if err != nil {
+		return access, err
+	}
+
+	switch {
+	case ownerID == userID:
+		access.Level = LevelOwner
+	case role.Valid:
+		access.Level = LevelMentor
+		access.Role = role.String
+	case studentID.Valid:
+		access.Level = LevelStudent
+	}
+
+	return access, nil
+}
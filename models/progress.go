@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LessonProgress struct {
+	LessonID    int        `json:"lesson_id"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// CourseProgress is the progress of one student on a course.
+type CourseProgress struct {
+	Progress         float64 `json:"progress"`
+	CompletedLessons int     `json:"completed_lessons"`
+	TotalLessons     int     `json:"total_lessons"`
+	NextLesson       *Lesson `json:"next_lesson"`
+}
+
+type ModelProgress struct {
+	model
+}
+
+type IProgressGetter interface {
+	GetCourseProgress(ctx context.Context, courseID int64, userID int64) (CourseProgress, error)
+}
+
+type IProgressTracker interface {
+	StartLesson(ctx context.Context, userID int64, lessonID int64) (LessonProgress, error)
+	CompleteLesson(ctx context.Context, userID int64, lessonID int64, courseID int64) (LessonProgress, error)
+	IProgressGetter
+}
+
+func NewProgressModel(db *sql.DB) ModelProgress {
+	return ModelProgress{model{db}}
+}
+
+func (m ModelProgress) getLessonProgress(ctx context.Context, userID int64, lessonID int64) (LessonProgress, error) {
+	progress := LessonProgress{LessonID: int(lessonID)}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			started_at, completed_at
+		FROM lesson_progress
+		WHERE user_id = ? AND lesson_id = ?
+	`, userID, lessonID)
+
+	err := row.Scan(&progress.StartedAt, &progress.CompletedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return LessonProgress{}, err
+	}
+
+	return progress, nil
+}
+
+// StartLesson records the first time the user opened the lesson. Starting it again changes nothing.
+func (m ModelProgress) StartLesson(ctx context.Context, userID int64, lessonID int64) (LessonProgress, error) {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO lesson_progress(user_id, lesson_id, started_at)
+		VALUE(?, ?, NOW())
+		ON DUPLICATE KEY UPDATE started_at = started_at
+	`, userID, lessonID)
+	if err != nil {
+		return LessonProgress{}, err
+	}
+
+	return m.getLessonProgress(ctx, userID, lessonID)
+}
+
+// CompleteLesson marks the lesson completed and recomputes students.progress for the course.
+func (m ModelProgress) CompleteLesson(ctx context.Context, userID int64, lessonID int64, courseID int64) (LessonProgress, error) {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO lesson_progress(user_id, lesson_id, started_at, completed_at)
+		VALUE(?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE completed_at = COALESCE(completed_at, NOW())
+	`, userID, lessonID)
+	if err != nil {
+		return LessonProgress{}, err
+	}
+
+	if err = m.RecomputeCourseProgress(ctx, courseID, userID); err != nil {
+		return LessonProgress{}, err
+	}
+
+	return m.getLessonProgress(ctx, userID, lessonID)
+}
+
+// countLessons returns how many lessons the course has and how many of them the user completed.
+func (m ModelProgress) countLessons(ctx context.Context, courseID int64, userID int64) (total int, completed int, err error) {
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(l.id), COUNT(p.completed_at)
+		FROM lessons l
+		LEFT JOIN lesson_progress p ON p.lesson_id = l.id AND p.user_id = ?
+		WHERE l.course_id = ?
+	`, userID, courseID)
+
+	err = row.Scan(&total, &completed)
+
+	return total, completed, err
+}
+
+func percent(completed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(completed) * 100 / float64(total)
+}
+
+// RecomputeCourseProgress writes the share of completed lessons, in percent, to students.progress.
+func (m ModelProgress) RecomputeCourseProgress(ctx context.Context, courseID int64, userID int64) error {
+	total, completed, err := m.countLessons(ctx, courseID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, `
+		UPDATE students SET progress = ? WHERE course_id = ? AND user_id = ?
+	`, percent(completed, total), courseID, userID)
+
+	return err
+}
+
+// recomputeStudentsProgress rewrites students.progress for every student of the course, after its
+// lessons changed.
+func recomputeStudentsProgress(ctx context.Context, db execQuerier, courseID int64) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE students s SET s.progress = (
+			SELECT COALESCE(COUNT(p.completed_at) * 100 / NULLIF(COUNT(l.id), 0), 0)
+			FROM lessons l
+			LEFT JOIN lesson_progress p ON p.lesson_id = l.id AND p.user_id = s.user_id
+			WHERE l.course_id = s.course_id
+		)
+		WHERE s.course_id = ?
+	`, courseID)
+
+	return err
+}
+
+// GetCourseProgress returns the user's progress on the course and the first lesson they haven't completed yet.
+func (m ModelProgress) GetCourseProgress(ctx context.Context, courseID int64, userID int64) (CourseProgress, error) {
+	total, completed, err := m.countLessons(ctx, courseID, userID)
+	if err != nil {
+		return CourseProgress{}, err
+	}
+
+	progress := CourseProgress{
+		Progress:         percent(completed, total),
+		CompletedLessons: completed,
+		TotalLessons:     total,
+	}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			l.id, l.title, l.theme, l.description, l.number, l.header_ava, l.course_id
+		FROM lessons l
+		LEFT JOIN lesson_progress p ON p.lesson_id = l.id AND p.user_id = ?
+		WHERE l.course_id = ? AND p.completed_at IS NULL
+		ORDER BY l.number
+		LIMIT 1
+	`, userID, courseID)
+
+	var lesson Lesson
+	err = row.Scan(&lesson.ID, &lesson.Title, &lesson.Theme, &lesson.Description, &lesson.Number, &lesson.Image, &lesson.CourseID)
+	if err == nil {
+		progress.NextLesson = &lesson
+	} else if err != sql.ErrNoRows {
+		return CourseProgress{}, err
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,649 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	QuestionSingleChoice   = "single_choice"
+	QuestionMultipleChoice = "multiple_choice"
+	QuestionFreeText       = "free_text"
+)
+
+// QuestionAnswer is the correct answer of a question: option indexes for choice questions,
+// accepted texts for free-text ones. A free-text question without accepted texts is graded by a mentor.
+type QuestionAnswer struct {
+	Choices  []int    `json:"choices,omitempty"`
+	Accepted []string `json:"accepted,omitempty"`
+}
+
+type Question struct {
+	ID      int             `json:"id"`
+	Number  int             `json:"number"`
+	Type    string          `json:"type"`
+	Text    string          `json:"text"`
+	Options []string        `json:"options,omitempty"`
+	Points  int             `json:"points"`
+	Answer  *QuestionAnswer `json:"answer,omitempty"`
+}
+
+type Quiz struct {
+	ID                   int        `json:"id"`
+	LessonID             int        `json:"lesson_id"`
+	Title                string     `json:"title"`
+	PassScore            float64    `json:"pass_score"`
+	CountsTowardProgress bool       `json:"counts_toward_progress"`
+	Questions            []Question `json:"questions"`
+}
+
+type QuestionInput struct {
+	Type    string         `json:"type" binding:"required"`
+	Text    string         `json:"text" binding:"required"`
+	Options []string       `json:"options"`
+	Points  int            `json:"points"`
+	Answer  QuestionAnswer `json:"answer"`
+}
+
+type QuizInput struct {
+	Title                string          `json:"title" binding:"required"`
+	PassScore            float64         `json:"pass_score"`
+	CountsTowardProgress bool            `json:"counts_toward_progress"`
+	Questions            []QuestionInput `json:"questions" binding:"required"`
+}
+
+// QuestionResponse is a student's answer to one question.
+type QuestionResponse struct {
+	QuestionID int    `json:"question_id"`
+	Choices    []int  `json:"choices,omitempty"`
+	Text       string `json:"text,omitempty"`
+}
+
+// GradedResponse is a response with its grading. Correct is nil while the response waits for a mentor.
+// MaxPoints is what the question was worth when the attempt was made.
+type GradedResponse struct {
+	QuestionResponse
+	Correct   *bool `json:"correct"`
+	Points    int   `json:"points"`
+	MaxPoints int   `json:"max_points"`
+}
+
+// ResponseGrade is a mentor's grading of a free-text response that waits for review.
+type ResponseGrade struct {
+	QuestionID int  `json:"question_id"`
+	Correct    bool `json:"correct"`
+}
+
+type AttemptReviewInput struct {
+	Grades []ResponseGrade `json:"grades" binding:"required"`
+}
+
+type Attempt struct {
+	ID            int              `json:"id"`
+	QuizID        int              `json:"quiz_id"`
+	UserID        int              `json:"user_id"`
+	Score         int              `json:"score"`
+	MaxScore      int              `json:"max_score"`
+	Percent       float64          `json:"percent"`
+	Passed        bool             `json:"passed"`
+	PendingReview bool             `json:"pending_review"`
+	Responses     []GradedResponse `json:"responses"`
+	DateCreated   time.Time        `json:"date_created"`
+}
+
+var (
+	ErrQuestionInvalid error = ValidationError("question has an unknown type or an answer that doesn't match its options")
+	ErrAttemptReviewed error = ConflictError("the attempt has no responses waiting for review")
+	ErrGradeInvalid    error = ValidationError("grades must name responses of the attempt that wait for review, each once")
+)
+
+func validChoices(choices []int, options int) bool {
+	seen := make(map[int]bool)
+	for _, choice := range choices {
+		if choice < 0 || choice >= options || seen[choice] {
+			return false
+		}
+		seen[choice] = true
+	}
+
+	return true
+}
+
+// ValidateQuiz checks every question of the input and fills in default points.
+func ValidateQuiz(in *QuizInput) error {
+	for i := range in.Questions {
+		q := &in.Questions[i]
+
+		if q.Points <= 0 {
+			q.Points = 1
+		}
+
+		switch q.Type {
+		case QuestionSingleChoice:
+			if len(q.Options) < 2 || len(q.Answer.Choices) != 1 || !validChoices(q.Answer.Choices, len(q.Options)) {
+				return ErrQuestionInvalid
+			}
+		case QuestionMultipleChoice:
+			if len(q.Options) < 2 || len(q.Answer.Choices) == 0 || !validChoices(q.Answer.Choices, len(q.Options)) {
+				return ErrQuestionInvalid
+			}
+		case QuestionFreeText:
+			q.Options = nil
+			q.Answer.Choices = nil
+		default:
+			return ErrQuestionInvalid
+		}
+	}
+
+	return nil
+}
+
+func sameChoices(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[int]bool)
+	for _, choice := range a {
+		set[choice] = true
+	}
+	for _, choice := range b {
+		if !set[choice] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Grade scores the responses against the quiz. Choice questions are all-or-nothing, free-text questions
+// match accepted answers case-insensitively or are left for a mentor when they have none.
+func Grade(quiz Quiz, responses []QuestionResponse) Attempt {
+	attempt := Attempt{QuizID: quiz.ID, Responses: make([]GradedResponse, 0, len(quiz.Questions))}
+
+	byQuestion := make(map[int]QuestionResponse)
+	for _, response := range responses {
+		byQuestion[response.QuestionID] = response
+	}
+
+	for _, q := range quiz.Questions {
+		attempt.MaxScore += q.Points
+
+		graded := GradedResponse{QuestionResponse: byQuestion[q.ID], MaxPoints: q.Points}
+		graded.QuestionID = q.ID
+
+		var correct bool
+		switch {
+		case q.Answer == nil:
+			// Answers are always loaded for grading; treat a missing one as wrong.
+		case q.Type == QuestionFreeText && len(q.Answer.Accepted) == 0:
+			attempt.PendingReview = true
+			attempt.Responses = append(attempt.Responses, graded)
+			continue
+		case q.Type == QuestionFreeText:
+			text := strings.TrimSpace(graded.Text)
+			for _, accepted := range q.Answer.Accepted {
+				if strings.EqualFold(text, strings.TrimSpace(accepted)) {
+					correct = true
+					break
+				}
+			}
+		default:
+			correct = sameChoices(graded.Choices, q.Answer.Choices)
+		}
+
+		graded.Correct = &correct
+		if correct {
+			graded.Points = q.Points
+			attempt.Score += q.Points
+		}
+		attempt.Responses = append(attempt.Responses, graded)
+	}
+
+	attempt.Percent = percent(attempt.Score, attempt.MaxScore)
+	attempt.Passed = !attempt.PendingReview && attempt.Percent >= quiz.PassScore
+
+	return attempt
+}
+
+// applyGrades grades the responses of the attempt that wait for review and recomputes its score. The
+// attempt stays pending while some of them are left ungraded. Responses recorded without their
+// question's points take them from quiz.
+func applyGrades(quiz Quiz, attempt *Attempt, grades []ResponseGrade) error {
+	points := make(map[int]int)
+	for _, q := range quiz.Questions {
+		points[q.ID] = q.Points
+	}
+
+	graded := make(map[int]bool)
+	for _, grade := range grades {
+		i := -1
+		for j, response := range attempt.Responses {
+			if response.QuestionID == grade.QuestionID && response.Correct == nil {
+				i = j
+				break
+			}
+		}
+		if i < 0 || graded[grade.QuestionID] {
+			return ErrGradeInvalid
+		}
+		graded[grade.QuestionID] = true
+
+		response := &attempt.Responses[i]
+		if response.MaxPoints == 0 {
+			if response.MaxPoints = points[response.QuestionID]; response.MaxPoints == 0 {
+				return ErrGradeInvalid
+			}
+		}
+
+		correct := grade.Correct
+		response.Correct = &correct
+		if correct {
+			response.Points = response.MaxPoints
+		}
+	}
+
+	attempt.Score, attempt.PendingReview = 0, false
+	for _, response := range attempt.Responses {
+		attempt.Score += response.Points
+		if response.Correct == nil {
+			attempt.PendingReview = true
+		}
+	}
+
+	attempt.Percent = percent(attempt.Score, attempt.MaxScore)
+	attempt.Passed = !attempt.PendingReview && attempt.Percent >= quiz.PassScore
+
+	return nil
+}
+
+type ModelQuiz struct {
+	model
+}
+
+type IQuizLister interface {
+	GetList(ctx context.Context, lessonID int64) ([]Quiz, error)
+}
+
+type IQuizGetter interface {
+	Get(ctx context.Context, id int64) (Quiz, error)
+}
+
+type IQuizCreator interface {
+	Create(ctx context.Context, lessonID int64, in QuizInput) (int64, error)
+	IQuizGetter
+}
+
+type IQuizUpdater interface {
+	Update(ctx context.Context, id int64, in QuizInput) error
+	IQuizGetter
+}
+
+type IQuizDeleter interface {
+	Delete(ctx context.Context, id int64) error
+	IQuizGetter
+}
+
+type IAttemptCreator interface {
+	CreateAttempt(ctx context.Context, in Attempt) (int64, error)
+	IQuizGetter
+}
+
+type IAttemptLister interface {
+	GetAttempts(ctx context.Context, quizID int64, userID int64) ([]Attempt, error)
+	IQuizGetter
+}
+
+type IAttemptReviewer interface {
+	ReviewAttempt(ctx context.Context, quiz Quiz, attemptID int64, grades []ResponseGrade) (Attempt, error)
+	IQuizGetter
+}
+
+func NewQuizModel(db *sql.DB) ModelQuiz {
+	return ModelQuiz{model{db}}
+}
+
+func (m ModelQuiz) getQuestions(ctx context.Context, quizID int64) ([]Question, error) {
+	questions := make([]Question, 0)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			id, number, type, text, options, points, answer
+		FROM quiz_questions
+		WHERE quiz_id = ?
+		ORDER BY number
+	`, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var question Question
+		var options, answer []byte
+
+		err = rows.Scan(&question.ID, &question.Number, &question.Type, &question.Text, &options, &question.Points, &answer)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(options, &question.Options); err != nil {
+			return nil, err
+		}
+		question.Answer = &QuestionAnswer{}
+		if err = json.Unmarshal(answer, question.Answer); err != nil {
+			return nil, err
+		}
+
+		questions = append(questions, question)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return questions, nil
+}
+
+func (m ModelQuiz) GetList(ctx context.Context, lessonID int64) ([]Quiz, error) {
+	quizzes := make([]Quiz, 0)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			id, lesson_id, title, pass_score, counts_toward_progress
+		FROM quizzes
+		WHERE lesson_id = ?
+		ORDER BY id
+	`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var quiz Quiz
+
+		err = rows.Scan(&quiz.ID, &quiz.LessonID, &quiz.Title, &quiz.PassScore, &quiz.CountsTowardProgress)
+		if err != nil {
+			return nil, err
+		}
+
+		quizzes = append(quizzes, quiz)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range quizzes {
+		if quizzes[i].Questions, err = m.getQuestions(ctx, int64(quizzes[i].ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	return quizzes, nil
+}
+
+func (m ModelQuiz) Get(ctx context.Context, id int64) (Quiz, error) {
+	quiz := Quiz{}
+
+	row := m.db.QueryRowContext(ctx, `
+		SELECT
+			id, lesson_id, title, pass_score, counts_toward_progress
+		FROM quizzes
+		WHERE id = ?
+	`, id)
+
+	err := row.Scan(&quiz.ID, &quiz.LessonID, &quiz.Title, &quiz.PassScore, &quiz.CountsTowardProgress)
+	if err == sql.ErrNoRows {
+		return Quiz{}, ErrNotFound
+	}
+	if err != nil {
+		return Quiz{}, err
+	}
+
+	if quiz.Questions, err = m.getQuestions(ctx, id); err != nil {
+		return Quiz{}, err
+	}
+
+	return quiz, nil
+}
+
+// questionURLs returns the links written into the text and options of the questions.
+func questionURLs(questions []QuestionInput) []string {
+	texts := make([]string, 0, len(questions))
+	for _, q := range questions {
+		texts = append(texts, q.Text)
+		texts = append(texts, q.Options...)
+	}
+
+	return textURLs(texts...)
+}
+
+func insertQuestions(ctx context.Context, tx *sql.Tx, quizID int64, questions []QuestionInput) error {
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO quiz_questions(
+			quiz_id, number, type, text, options, points, answer
+		) VALUE(?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, q := range questions {
+		options, err := json.Marshal(q.Options)
+		if err != nil {
+			return err
+		}
+
+		answer, err := json.Marshal(q.Answer)
+		if err != nil {
+			return err
+		}
+
+		if _, err = stmt.ExecContext(ctx, quizID, i+1, q.Type, q.Text, options, q.Points, answer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Create stores the quiz with its questions. The input must have passed ValidateQuiz.
+func (m ModelQuiz) Create(ctx context.Context, lessonID int64, in QuizInput) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO quizzes(
+			lesson_id, title, pass_score, counts_toward_progress
+		) VALUE(?, ?, ?, ?)
+	`, lessonID, in.Title, in.PassScore, in.CountsTowardProgress)
+	if err != nil {
+		return 0, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = insertQuestions(ctx, tx, lastID, in.Questions); err != nil {
+		return 0, err
+	}
+
+	if err = syncFileRefs(ctx, tx, refQuiz, lastID, questionURLs(in.Questions)...); err != nil {
+		return 0, err
+	}
+
+	return lastID, tx.Commit()
+}
+
+// Update replaces the quiz settings and all of its questions. Earlier attempts keep their grading.
+func (m ModelQuiz) Update(ctx context.Context, id int64, in QuizInput) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE quizzes SET
+			title = ?,
+			pass_score = ?,
+			counts_toward_progress = ?
+		WHERE id = ?
+	`, in.Title, in.PassScore, in.CountsTowardProgress, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM quiz_questions WHERE quiz_id = ?`, id); err != nil {
+		return err
+	}
+
+	if err = insertQuestions(ctx, tx, id, in.Questions); err != nil {
+		return err
+	}
+
+	if err = syncFileRefs(ctx, tx, refQuiz, id, questionURLs(in.Questions)...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ModelQuiz) Delete(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM quizzes WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func (m ModelQuiz) CreateAttempt(ctx context.Context, in Attempt) (int64, error) {
+	responses, err := json.Marshal(in.Responses)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO quiz_attempts(
+			quiz_id, user_id, score, max_score, passed, pending_review, responses, date_created
+		) VALUE(?, ?, ?, ?, ?, ?, ?, NOW())
+	`, in.QuizID, in.UserID, in.Score, in.MaxScore, in.Passed, in.PendingReview, responses)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+const attemptColumns = `
+	id, quiz_id, user_id, score, max_score, passed, pending_review, responses, date_created
+`
+
+func scanAttempt(scanner interface{ Scan(...interface{}) error }) (Attempt, error) {
+	var attempt Attempt
+	var responses []byte
+
+	err := scanner.Scan(&attempt.ID, &attempt.QuizID, &attempt.UserID, &attempt.Score, &attempt.MaxScore,
+		&attempt.Passed, &attempt.PendingReview, &responses, &attempt.DateCreated)
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	if err = json.Unmarshal(responses, &attempt.Responses); err != nil {
+		return Attempt{}, err
+	}
+	attempt.Percent = percent(attempt.Score, attempt.MaxScore)
+
+	return attempt, nil
+}
+
+// GetAttempts returns the attempts on a quiz, newest first. A zero userID returns attempts of every user.
+func (m ModelQuiz) GetAttempts(ctx context.Context, quizID int64, userID int64) ([]Attempt, error) {
+	attempts := make([]Attempt, 0)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT `+attemptColumns+`
+		FROM quiz_attempts
+		WHERE quiz_id = ? AND (? = 0 OR user_id = ?)
+		ORDER BY date_created DESC, id DESC
+	`, quizID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attempt, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// ReviewAttempt grades the free-text responses of an attempt on the quiz that wait for a mentor, and
+// stores its new score and whether it passed.
+func (m ModelQuiz) ReviewAttempt(ctx context.Context, quiz Quiz, attemptID int64, grades []ResponseGrade) (Attempt, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Attempt{}, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `
+		SELECT `+attemptColumns+` FROM quiz_attempts WHERE id = ? AND quiz_id = ? FOR UPDATE
+	`, attemptID, quiz.ID)
+
+	attempt, err := scanAttempt(row)
+	if err == sql.ErrNoRows {
+		return Attempt{}, ErrNotFound
+	}
+	if err != nil {
+		return Attempt{}, err
+	}
+	if !attempt.PendingReview {
+		return Attempt{}, ErrAttemptReviewed
+	}
+
+	if err = applyGrades(quiz, &attempt, grades); err != nil {
+		return Attempt{}, err
+	}
+
+	responses, err := json.Marshal(attempt.Responses)
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE quiz_attempts SET
+			score = ?,
+			passed = ?,
+			pending_review = ?,
+			responses = ?
+		WHERE id = ?
+	`, attempt.Score, attempt.Passed, attempt.PendingReview, responses, attempt.ID)
+	if err != nil {
+		return Attempt{}, err
+	}
+
+	return attempt, tx.Commit()
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxBulkEnroll caps how many user names one bulk enrollment may list.
+const maxBulkEnroll = 500
+
+// RosterEntry is a student of a course as mentors see them.
+type RosterEntry struct {
+	User         User       `json:"user"`
+	Progress     float64    `json:"progress"`
+	DateEntered  time.Time  `json:"date_entered"`
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+type BulkEnrollInput struct {
+	UserNames []string `json:"user_names" binding:"required"`
+}
+
+// BulkEnrollSkip is a user name a bulk enrollment left out, with Reason one of "not_found",
+// "already_entered" and "full".
+type BulkEnrollSkip struct {
+	UserName string `json:"user_name"`
+	Reason   string `json:"reason"`
+}
+
+type BulkEnrollResult struct {
+	Entered []string         `json:"entered"`
+	Skipped []BulkEnrollSkip `json:"skipped"`
+}
+
+type IRosterManager interface {
+	GetRoster(ctx context.Context, courseID int64, page PageRequest, search string) ([]RosterEntry, PageInfo, error)
+	CountRoster(ctx context.Context, courseID int64, search string) (int, error)
+	EnrollUsers(ctx context.Context, courseID int64, userNames []string) (BulkEnrollResult, error)
+	RemoveStudent(ctx context.Context, courseID int64, userID int64) error
+}
+
+var rosterSortKeys = map[string]sortKey{
+	"entered":  {column: "s.date_entered"},
+	"name":     {column: "u.user_name"},
+	"progress": {column: "s.progress", desc: true},
+}
+
+// noActivity stands in for a missing date in lastActivity, since GREATEST is NULL as soon as one
+// of its arguments is.
+const noActivity = `CAST('1000-01-01 00:00:00' AS DATETIME)`
+
+// lastActivity is the latest time the student of the row s started or completed a lesson, attempted
+// a quiz or submitted an assignment of the course, or NULL if they did none of that.
+const lastActivity = `NULLIF(GREATEST(
+	COALESCE((
+		SELECT MAX(COALESCE(lp.completed_at, lp.started_at))
+		FROM lesson_progress lp JOIN lessons l ON l.id = lp.lesson_id
+		WHERE l.course_id = s.course_id AND lp.user_id = s.user_id
+	), ` + noActivity + `),
+	COALESCE((
+		SELECT MAX(qa.date_created)
+		FROM quiz_attempts qa JOIN quizzes q ON q.id = qa.quiz_id JOIN lessons l ON l.id = q.lesson_id
+		WHERE l.course_id = s.course_id AND qa.user_id = s.user_id
+	), ` + noActivity + `),
+	COALESCE((
+		SELECT MAX(sub.date_submitted)
+		FROM submissions sub JOIN assignments a ON a.id = sub.assignment_id JOIN lessons l ON l.id = a.lesson_id
+		WHERE l.course_id = s.course_id AND sub.user_id = s.user_id
+	), ` + noActivity + `)
+), ` + noActivity + `)`
+
+// GetRoster returns a page of the course's students whose user or full name contains search.
+func (m ModelCourse) GetRoster(ctx context.Context, courseID int64, page PageRequest, search string) ([]RosterEntry, PageInfo, error) {
+	k, err := newKeyset(page, rosterSortKeys, "entered", "s.user_id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	cond, condArgs := k.where()
+	order, orderArgs := k.orderLimit()
+	args := append(append([]interface{}{courseID, "%" + search + "%", "%" + search + "%"}, condArgs...), orderArgs...)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+			u.id, u.user_name, u.full_name, u.avatar, u.about,
+			s.progress, s.date_entered, `+lastActivity+`, `+k.column()+`
+		FROM students s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.course_id = ? AND (u.user_name LIKE ? OR u.full_name LIKE ?) AND `+cond+`
+		`+order, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	defer rows.Close()
+
+	roster := make([]RosterEntry, 0)
+	values := make([]string, 0)
+	ids := make([]int64, 0)
+
+	for rows.Next() {
+		var entry RosterEntry
+		var value string
+
+		err = rows.Scan(
+			&entry.User.ID, &entry.User.Name, &entry.User.FullName, &entry.User.Avatar, &entry.User.About,
+			&entry.Progress, &entry.DateEntered, &entry.LastActivity, &value,
+		)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+
+		roster = append(roster, entry)
+		values = append(values, value)
+		ids = append(ids, int64(entry.User.ID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	n, info := k.finish(roster, values, ids)
+
+	return roster[:n], info, nil
+}
+
+func (m ModelCourse) CountRoster(ctx context.Context, courseID int64, search string) (int, error) {
+	var count int
+
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM students s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.course_id = ? AND (u.user_name LIKE ? OR u.full_name LIKE ?)
+	`, courseID, "%"+search+"%", "%"+search+"%").Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// EnrollUsers makes the named users students of the course, regardless of its enrollment mode and window,
+// but not beyond its capacity. Names that can't be enrolled are skipped with the reason.
+func (m ModelCourse) EnrollUsers(ctx context.Context, courseID int64, userNames []string) (BulkEnrollResult, error) {
+	result := BulkEnrollResult{Entered: make([]string, 0), Skipped: make([]BulkEnrollSkip, 0)}
+
+	if len(userNames) > maxBulkEnroll {
+		return result, ValidationError(fmt.Sprintf("at most %d user names can be enrolled at once, got %d", maxBulkEnroll, len(userNames)))
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	state, err := lockEnrollmentState(ctx, tx, courseID)
+	if err != nil {
+		return result, err
+	}
+	if state.status == CourseDraft {
+		return result, ErrEnrollmentNotPublished
+	}
+
+	seen := make(map[string]bool, len(userNames))
+	for _, name := range userNames {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var userID int64
+		err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE user_name = ?`, name).Scan(&userID)
+		if err == sql.ErrNoRows {
+			result.Skipped = append(result.Skipped, BulkEnrollSkip{name, "not_found"})
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		student, err := isStudent(ctx, tx, courseID, userID)
+		if err != nil {
+			return result, err
+		}
+		if student {
+			result.Skipped = append(result.Skipped, BulkEnrollSkip{name, "already_entered"})
+			continue
+		}
+
+		if state.full() {
+			result.Skipped = append(result.Skipped, BulkEnrollSkip{name, "full"})
+			continue
+		}
+
+		if err = admit(ctx, tx, courseID, userID); err != nil {
+			return result, err
+		}
+		state.students++
+		result.Entered = append(result.Entered, name)
+	}
+
+	return result, tx.Commit()
+}
+
+// RemoveStudent takes the user off the course's students like Leave does, failing with ErrNotFound
+// when they aren't one.
+func (m ModelCourse) RemoveStudent(ctx context.Context, courseID int64, userID int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = lockEnrollmentState(ctx, tx, courseID); err != nil {
+		return err
+	}
+
+	student, err := isStudent(ctx, tx, courseID, userID)
+	if err != nil {
+		return err
+	}
+	if !student {
+		return ErrNotFound
+	}
+
+	if err = leaveCourse(ctx, tx, courseID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,318 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// CourseFilter narrows the catalogue listing. Zero values don't filter.
+type CourseFilter struct {
+	// Search is matched against the title, the description and the mentors' names.
+	Search   string
+	MentorID int64
+	OwnerID  int64
+	// ManagerID keeps the courses the user owns or mentors.
+	ManagerID int64
+	// Category is a category slug. Courses in its subcategories match too.
+	Category string
+	// Tags keeps the courses that have every one of them.
+	Tags []string
+	// Status keeps the courses in that status. Drafts the user doesn't own or mentor never match.
+	Status string
+	// Enrolled, when set, keeps only the courses the user has (or hasn't) entered.
+	Enrolled *bool
+}
+
+// relevance scores a course against the search text. Title matches weigh double and mentor names
+// count as much as the description. It takes the search text three times.
+const relevance = `(
+	2 * MATCH(c.title) AGAINST (? IN NATURAL LANGUAGE MODE)
+	+ MATCH(c.title, c.description) AGAINST (? IN NATURAL LANGUAGE MODE)
+	+ COALESCE((
+		SELECT MAX(MATCH(u.full_name, u.user_name) AGAINST (? IN NATURAL LANGUAGE MODE))
+		FROM mentors m JOIN users u ON u.id = m.user_id
+		WHERE m.course_id = c.id
+	), 0)
+)`
+
+// where builds the WHERE clause selecting the filtered courses of the aliased table c.
+func (f CourseFilter) where(userID int64) (string, []interface{}) {
+	conditions := []string{`(
+		c.status <> 'draft' OR c.owner_id = ? OR c.id IN (SELECT course_id FROM mentors WHERE user_id = ?)
+	)`}
+	args := []interface{}{userID, userID}
+
+	if f.Status != "" {
+		conditions = append(conditions, `c.status = ?`)
+		args = append(args, f.Status)
+	}
+
+	if f.Search != "" {
+		conditions = append(conditions, `(
+			MATCH(c.title, c.description) AGAINST (? IN NATURAL LANGUAGE MODE)
+			OR c.id IN (
+				SELECT m.course_id FROM mentors m JOIN users u ON u.id = m.user_id
+				WHERE MATCH(u.full_name, u.user_name) AGAINST (? IN NATURAL LANGUAGE MODE)
+			)
+		)`)
+		args = append(args, f.Search, f.Search)
+	}
+
+	if f.MentorID != 0 {
+		conditions = append(conditions, `c.id IN (SELECT course_id FROM mentors WHERE user_id = ?)`)
+		args = append(args, f.MentorID)
+	}
+
+	if f.OwnerID != 0 {
+		conditions = append(conditions, `c.owner_id = ?`)
+		args = append(args, f.OwnerID)
+	}
+
+	if f.ManagerID != 0 {
+		conditions = append(conditions, `(c.owner_id = ? OR c.id IN (SELECT course_id FROM mentors WHERE user_id = ?))`)
+		args = append(args, f.ManagerID, f.ManagerID)
+	}
+
+	if f.Category != "" {
+		conditions = append(conditions, `c.category_id IN (
+			WITH RECURSIVE sub AS (
+				SELECT id FROM categories WHERE slug = ?
+				UNION ALL
+				SELECT cat.id FROM categories cat JOIN sub ON cat.parent_id = sub.id
+			)
+			SELECT id FROM sub
+		)`)
+		args = append(args, f.Category)
+	}
+
+	for _, tag := range f.Tags {
+		conditions = append(conditions, `c.id IN (
+			SELECT ct.course_id FROM course_tags ct JOIN tags t ON t.id = ct.tag_id WHERE t.name = ?
+		)`)
+		args = append(args, NormalizeTag(tag))
+	}
+
+	if f.Enrolled != nil {
+		in := "IN"
+		if !*f.Enrolled {
+			in = "NOT IN"
+		}
+		conditions = append(conditions, `c.id `+in+` (SELECT course_id FROM students WHERE user_id = ?)`)
+		args = append(args, userID)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+var courseSortKeys = map[string]sortKey{
+	"created":  {column: "c.id"},
+	"title":    {column: "c.title"},
+	"students": {column: "c.students_count", desc: true},
+}
+
+// scanCourses reads rows of id, title, description, owner_id, avatar, status, category_id, the enrollment
+// settings, students_count and the sort value as text.
+func scanCourses(rows *sql.Rows) ([]CourseDetail, []string, []int64, error) {
+	defer rows.Close()
+
+	courses := make([]CourseDetail, 0)
+	values := make([]string, 0)
+	ids := make([]int64, 0)
+
+	for rows.Next() {
+		var course CourseDetail
+		var value string
+
+		err := rows.Scan(
+			&course.ID, &course.Title, &course.Description, &course.OwnerID, &course.Avatar, &course.Status, &course.CategoryID,
+			&course.Enrollment.Mode, &course.Enrollment.Capacity, &course.Enrollment.OpensAt, &course.Enrollment.ClosesAt,
+			&course.Enrollment.Waitlist, &course.StudentsCount, &value,
+		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		courses = append(courses, course)
+		values = append(values, value)
+		ids = append(ids, course.ID)
+	}
+
+	return courses, values, ids, rows.Err()
+}
+
+// GetList returns a page of the courses matching the filter. Searches are ordered by relevance unless
+// another sort is asked for; that order is paged by offset only, every other sort also with cursors.
+func (m ModelCourse) GetList(ctx context.Context, page PageRequest, userID int64, filter CourseFilter) ([]CourseDetail, PageInfo, error) {
+	where, args := filter.where(userID)
+
+	if filter.Search != "" && (page.Sort == "" || page.Sort == SortRelevance) {
+		if page.Cursor != "" {
+			return nil, PageInfo{}, ValidationError("cursors can't be used when sorting by relevance")
+		}
+
+		args = append(args, filter.Search, filter.Search, filter.Search, page.Limit, page.Offset)
+
+		rows, err := m.db.QueryContext(ctx, `
+			SELECT
+			       c.id, c.title, c.description, c.owner_id, c.avatar, c.status, c.category_id,
+			       c.enrollment_mode, c.capacity, c.enrollment_opens, c.enrollment_closes, c.waitlist, c.students_count, ''
+			FROM courses c
+			WHERE `+where+`
+			ORDER BY `+relevance+` DESC, c.id
+			LIMIT ? OFFSET ?
+		`, args...)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+
+		courses, _, _, err := scanCourses(rows)
+		if err == nil {
+			err = m.fillListDetails(ctx, courses, userID)
+		}
+
+		return courses, PageInfo{}, err
+	}
+
+	k, err := newKeyset(page, courseSortKeys, "created", "c.id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	cond, condArgs := k.where()
+	order, orderArgs := k.orderLimit()
+	args = append(append(args, condArgs...), orderArgs...)
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT
+		       c.id, c.title, c.description, c.owner_id, c.avatar, c.status, c.category_id,
+		       c.enrollment_mode, c.capacity, c.enrollment_opens, c.enrollment_closes, c.waitlist, c.students_count,
+		       `+k.column()+`
+		FROM courses c
+		WHERE `+where+` AND `+cond+`
+		`+order, args...)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	courses, values, ids, err := scanCourses(rows)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	n, info := k.finish(courses, values, ids)
+	courses = courses[:n]
+
+	if err = m.fillListDetails(ctx, courses, userID); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	return courses, info, nil
+}
+
+// Count returns the number of courses matching the filter.
+func (m ModelCourse) Count(ctx context.Context, userID int64, filter CourseFilter) (int, error) {
+	where, args := filter.where(userID)
+
+	var count int
+	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM courses c WHERE `+where, args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// maxTagFacets is how many of the most used tags Facets counts.
+const maxTagFacets = 20
+
+type CategoryFacet struct {
+	Category
+	Count int `json:"count"`
+}
+
+type TagFacet struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the courses matching a filter by category and by tag.
+type Facets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Tags       []TagFacet      `json:"tags"`
+}
+
+// Facets counts the courses matching the filter in each category, including those of its subcategories,
+// and under the most used tags. Categories and tags without matching courses are left out.
+func (m ModelCourse) Facets(ctx context.Context, userID int64, filter CourseFilter) (Facets, error) {
+	where, args := filter.where(userID)
+	facets := Facets{Categories: make([]CategoryFacet, 0), Tags: make([]TagFacet, 0)}
+
+	categories, err := NewCategoryModel(m.db).GetList(ctx)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT c.category_id, COUNT(*)
+		FROM courses c
+		WHERE `+where+` AND c.category_id IS NOT NULL
+		GROUP BY c.category_id
+	`, args...)
+	if err != nil {
+		return Facets{}, err
+	}
+	defer rows.Close()
+
+	parents := make(map[int64]*int64, len(categories))
+	for _, category := range categories {
+		parents[category.ID] = category.ParentID
+	}
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var categoryID int64
+		var count int
+		if err = rows.Scan(&categoryID, &count); err != nil {
+			return Facets{}, err
+		}
+
+		for id := &categoryID; id != nil; id = parents[*id] {
+			counts[*id] += count
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return Facets{}, err
+	}
+
+	for _, category := range categories {
+		if counts[category.ID] > 0 {
+			facets.Categories = append(facets.Categories, CategoryFacet{category, counts[category.ID]})
+		}
+	}
+
+	tagRows, err := m.db.QueryContext(ctx, `
+		SELECT t.name, COUNT(*)
+		FROM courses c
+		JOIN course_tags ct ON ct.course_id = c.id
+		JOIN tags t ON t.id = ct.tag_id
+		WHERE `+where+`
+		GROUP BY t.id, t.name
+		ORDER BY COUNT(*) DESC, t.name
+		LIMIT ?
+	`, append(args, maxTagFacets)...)
+	if err != nil {
+		return Facets{}, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var facet TagFacet
+		if err = tagRows.Scan(&facet.Name, &facet.Count); err != nil {
+			return Facets{}, err
+		}
+		facets.Tags = append(facets.Tags, facet)
+	}
+
+	return facets, tagRows.Err()
+}
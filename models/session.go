@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ModelSession struct {
+	model
+}
+
+type ISessionCreator interface {
+	CreateRefreshToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) (int64, error)
+}
+
+type ISessionRefresher interface {
+	UseRefreshToken(ctx context.Context, tokenHash string) (int64, error)
+	ISessionCreator
+}
+
+type ISessionRevoker interface {
+	RevokeRefreshToken(ctx context.Context, userID int64, tokenHash string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int64) error
+}
+
+func NewSessionModel(db *sql.DB) ModelSession {
+	return ModelSession{model{db}}
+}
+
+func (m ModelSession) CreateRefreshToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) (int64, error) {
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens(
+			user_id, token_hash, expires_at, revoked
+		) VALUE(?, ?, ?, FALSE)
+	`, userID, tokenHash, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// UseRefreshToken revokes a valid refresh token and returns the id of its user. The token is revoked
+// by a single conditional update, so that of concurrent uses of the same token only one succeeds; the
+// others, like uses of unknown, revoked or expired tokens, fail with ErrNotFound.
+func (m ModelSession) UseRefreshToken(ctx context.Context, tokenHash string) (int64, error) {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked = TRUE
+		WHERE token_hash = ? AND revoked = FALSE AND expires_at > ?
+	`, tokenHash, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err = requireAffected(res); err != nil {
+		return 0, err
+	}
+
+	var userID int64
+	err = m.db.QueryRowContext(ctx, `SELECT user_id FROM refresh_tokens WHERE token_hash = ?`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+
+	return userID, err
+}
+
+// RevokeRefreshToken revokes one refresh token of the user. Tokens of other users are left alone.
+func (m ModelSession) RevokeRefreshToken(ctx context.Context, userID int64, tokenHash string) error {
+	_, err := m.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked = TRUE WHERE token_hash = ? AND user_id = ?
+	`, tokenHash, userID)
+
+	return err
+}
+
+func (m ModelSession) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	_, err := m.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = ?`, userID)
+
+	return err
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Course statuses. A course starts as a draft that only its owner and mentors see, is published to
+// everyone and can be archived, which makes it read-only.
+const (
+	CourseDraft     = "draft"
+	CoursePublished = "published"
+	CourseArchived  = "archived"
+)
+
+// courseTransitions lists the statuses a course can move to from each status.
+var courseTransitions = map[string][]string{
+	CourseDraft:     {CoursePublished, CourseArchived},
+	CoursePublished: {CourseArchived},
+	CourseArchived:  {CoursePublished},
+}
+
+type CourseStatusInput struct {
+	Status string `json:"status" binding:"required"`
+}
+
+type ICourseStatusEditor interface {
+	SetStatus(ctx context.Context, courseID int64, status string) error
+	ICourseGetter
+}
+
+// SetStatus moves the course to the given status if its current status allows it.
+func (m ModelCourse) SetStatus(ctx context.Context, courseID int64, status string) error {
+	if _, ok := courseTransitions[status]; !ok {
+		return ValidationError(fmt.Sprintf("status must be %q, %q or %q, got %q", CourseDraft, CoursePublished, CourseArchived, status))
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM courses WHERE id = ? FOR UPDATE`, courseID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if current == status {
+		return nil
+	}
+
+	allowed := false
+	for _, next := range courseTransitions[current] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return ConflictError(fmt.Sprintf("a %s course can't be moved to %s", current, status))
+	}
+
+	if _, err = tx.ExecContext(ctx, `UPDATE courses SET status = ? WHERE id = ?`, status, courseID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxTagLength    = 64
+	maxTagsOfCourse = 20
+)
+
+// NormalizeTag lowercases the tag and collapses its whitespace, so "Go  Basics" and "go basics" are one tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// normalizeTags normalizes and deduplicates the tags of a course, dropping empty ones.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	list := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, ValidationError(fmt.Sprintf("tags can be at most %d characters long, %q is longer", maxTagLength, tag))
+		}
+
+		seen[tag] = true
+		list = append(list, tag)
+	}
+
+	if len(list) > maxTagsOfCourse {
+		return nil, ValidationError(fmt.Sprintf("a course can have at most %d tags, got %d", maxTagsOfCourse, len(list)))
+	}
+
+	return list, nil
+}
+
+// syncTags replaces the tags of the course, creating the tags that don't exist yet.
+func syncTags(ctx context.Context, tx *sql.Tx, courseID int64, tags []string) error {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM course_tags WHERE course_id = ?`, courseID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err = tx.ExecContext(ctx, `INSERT IGNORE INTO tags(name) VALUE(?)`, tag); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO course_tags(course_id, tag_id)
+			SELECT ?, id FROM tags WHERE name = ?
+		`, courseID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectTags returns the tag names of the given courses, keyed by course id.
+func selectTags(ctx context.Context, db *sql.DB, courseIDs []int64) (map[int64][]string, error) {
+	tags := make(map[int64][]string, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return tags, nil
+	}
+
+	in, args := inClause(courseIDs)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			ct.course_id, t.name
+		FROM course_tags ct
+		JOIN tags t ON t.id = ct.tag_id
+		WHERE ct.course_id IN `+in+`
+		ORDER BY t.name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var courseID int64
+		var name string
+		if err = rows.Scan(&courseID, &name); err != nil {
+			return nil, err
+		}
+		tags[courseID] = append(tags[courseID], name)
+	}
+
+	return tags, rows.Err()
+}
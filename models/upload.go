@@ -0,0 +1,405 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	UploadOpen       = "open"
+	UploadCompleting = "completing"
+	UploadCompleted  = "completed"
+)
+
+// Limits of upload sessions. Every chunk but the last must be exactly the session's chunk size.
+const (
+	MinChunkSize     = 1 << 20
+	MaxChunkSize     = 50 << 20
+	DefaultChunkSize = 8 << 20
+	MaxSessionSize   = 2 << 30
+)
+
+// UploadTTL is how long an upload session can be resumed after it was started.
+const UploadTTL = 24 * time.Hour
+
+// UploadClaimTimeout is how long a completion may hold a session. A completion that died leaves the
+// session completing, and once this long has passed another completion or a cancellation takes it over.
+const UploadClaimTimeout = 30 * time.Minute
+
+var (
+	checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	ErrUploadState error = ConflictError("the upload is not open")
+)
+
+// UploadSession is a file being uploaded in chunks numbered from 1. Chunks lists the ones received so
+// far and Missing the others, so that a client can resume after a disconnect by sending those.
+type UploadSession struct {
+	ID          int64         `json:"id"`
+	OwnerID     int64         `json:"owner_id"`
+	Name        string        `json:"name"`
+	ContentType string        `json:"content_type"`
+	Size        int64         `json:"size"`
+	ChunkSize   int64         `json:"chunk_size"`
+	ChunkCount  int           `json:"chunk_count"`
+	Checksum    string        `json:"checksum"`
+	Status      string        `json:"status"`
+	FileID      *int64        `json:"file_id"`
+	Chunks      []UploadChunk `json:"chunks"`
+	Missing     []int         `json:"missing"`
+	DateCreated time.Time     `json:"date_created"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+}
+
+type UploadChunk struct {
+	Number   int    `json:"number"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// UploadInput starts an upload session. Checksum is the SHA-256 of the whole file in hex, which is
+// verified when the upload is completed.
+type UploadInput struct {
+	Name        string `json:"name" binding:"required"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size" binding:"required"`
+	ChunkSize   int64  `json:"chunk_size"`
+	Checksum    string `json:"checksum" binding:"required"`
+}
+
+type ModelUpload struct {
+	model
+}
+
+type IUploadManager interface {
+	Create(ctx context.Context, ownerID int64, in UploadInput) (int64, error)
+	Get(ctx context.Context, id int64) (UploadSession, error)
+	AddChunk(ctx context.Context, id int64, chunk UploadChunk) error
+	DropChunk(ctx context.Context, id int64, number int) error
+	Claim(ctx context.Context, id int64) (int64, error)
+	Release(ctx context.Context, id int64, claim int64) error
+	Complete(ctx context.Context, id int64, claim int64, fileID int64) error
+	Cancel(ctx context.Context, id int64) error
+}
+
+type IUploadCollector interface {
+	GetExpired(ctx context.Context, limit int) ([]UploadSession, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+func NewUploadModel(db *sql.DB) ModelUpload {
+	return ModelUpload{model{db}}
+}
+
+// UploadChunkKey is where a received chunk of an upload session is kept until the upload is completed.
+func UploadChunkKey(sessionID int64, number int) string {
+	return fmt.Sprintf("uploads/%d/%d", sessionID, number)
+}
+
+func chunkCount(size, chunkSize int64) int {
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// ChunkSizeOf returns the size chunk number must have, or 0 when the session has no such chunk.
+func (s UploadSession) ChunkSizeOf(number int) int64 {
+	if number < 1 || number > s.ChunkCount {
+		return 0
+	}
+	if number == s.ChunkCount {
+		return s.Size - int64(number-1)*s.ChunkSize
+	}
+
+	return s.ChunkSize
+}
+
+// missing returns the numbers of the chunks that weren't received yet.
+func (s UploadSession) missing() []int {
+	received := make(map[int]bool, len(s.Chunks))
+	for _, chunk := range s.Chunks {
+		received[chunk.Number] = true
+	}
+
+	missing := make([]int, 0)
+	for number := 1; number <= s.ChunkCount; number++ {
+		if !received[number] {
+			missing = append(missing, number)
+		}
+	}
+
+	return missing
+}
+
+func (m ModelUpload) Create(ctx context.Context, ownerID int64, in UploadInput) (int64, error) {
+	if in.ChunkSize == 0 {
+		in.ChunkSize = DefaultChunkSize
+	}
+
+	switch {
+	case len(in.Name) > 255:
+		return 0, ValidationError("name can be at most 255 characters long")
+	case in.Size <= 0 || in.Size > MaxSessionSize:
+		return 0, ValidationError(fmt.Sprintf("size must be between 1 and %d bytes", int64(MaxSessionSize)))
+	case in.ChunkSize < MinChunkSize || in.ChunkSize > MaxChunkSize:
+		return 0, ValidationError(fmt.Sprintf("chunk_size must be between %d and %d bytes", MinChunkSize, MaxChunkSize))
+	case !checksumPattern.MatchString(in.Checksum):
+		return 0, ValidationError("checksum must be a SHA-256 digest in lowercase hex")
+	}
+
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO upload_sessions(
+			owner_id, name, content_type, size, chunk_size, checksum, status, date_created, expires_at
+		) VALUE(?, ?, ?, ?, ?, ?, ?, NOW(), NOW() + INTERVAL ? SECOND)
+	`, ownerID, in.Name, in.ContentType, in.Size, in.ChunkSize, in.Checksum, UploadOpen, int64(UploadTTL/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+func selectChunks(ctx context.Context, db *sql.DB, sessionID int64) ([]UploadChunk, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT number, size, checksum FROM upload_chunks WHERE session_id = ? ORDER BY number
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := make([]UploadChunk, 0)
+	for rows.Next() {
+		var chunk UploadChunk
+		if err = rows.Scan(&chunk.Number, &chunk.Size, &chunk.Checksum); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, rows.Err()
+}
+
+// Get returns the session with its received chunks. Sessions that expired before they were completed
+// read as not found.
+func (m ModelUpload) Get(ctx context.Context, id int64) (UploadSession, error) {
+	s := UploadSession{}
+
+	err := m.db.QueryRowContext(ctx, `
+		SELECT
+			id, owner_id, name, content_type, size, chunk_size, checksum, status, file_id, date_created, expires_at
+		FROM upload_sessions
+		WHERE id = ? AND (status = ? OR expires_at > NOW())
+	`, id, UploadCompleted).Scan(
+		&s.ID, &s.OwnerID, &s.Name, &s.ContentType, &s.Size, &s.ChunkSize, &s.Checksum, &s.Status,
+		&s.FileID, &s.DateCreated, &s.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return UploadSession{}, ErrNotFound
+	}
+	if err != nil {
+		return UploadSession{}, err
+	}
+	s.ChunkCount = chunkCount(s.Size, s.ChunkSize)
+
+	if s.Chunks, err = selectChunks(ctx, m.db, id); err != nil {
+		return UploadSession{}, err
+	}
+	s.Missing = s.missing()
+
+	return s, nil
+}
+
+// AddChunk records a received chunk of an open session, checking the status in the same statement
+// so that a chunk can't slip in once a completion claimed the session. Sending a chunk again replaces
+// it. The chunk is recorded before it is stored, and dropped again with DropChunk when that fails.
+func (m ModelUpload) AddChunk(ctx context.Context, id int64, chunk UploadChunk) error {
+	res, err := m.db.ExecContext(ctx, `
+		INSERT INTO upload_chunks(session_id, number, size, checksum, date_received)
+		SELECT id, ?, ?, ?, NOW() FROM upload_sessions WHERE id = ? AND status = ?
+		ON DUPLICATE KEY UPDATE size = VALUES(size), checksum = VALUES(checksum), date_received = NOW()
+	`, chunk.Number, chunk.Size, chunk.Checksum, id, UploadOpen)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	// Nothing changed: the session isn't open, or the same chunk was sent again within the second.
+	var status string
+	var checksum sql.NullString
+	err = m.db.QueryRowContext(ctx, `
+		SELECT s.status, c.checksum
+		FROM upload_sessions s
+		LEFT JOIN upload_chunks c ON c.session_id = s.id AND c.number = ?
+		WHERE s.id = ?
+	`, chunk.Number, id).Scan(&status, &checksum)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != UploadOpen || checksum.String != chunk.Checksum {
+		return ErrUploadState
+	}
+
+	return nil
+}
+
+// DropChunk forgets a chunk of an open session whose data couldn't be stored, so that it is listed
+// as missing again.
+func (m ModelUpload) DropChunk(ctx context.Context, id int64, number int) error {
+	_, err := m.db.ExecContext(ctx, `
+		DELETE c FROM upload_chunks c
+		JOIN upload_sessions s ON s.id = c.session_id
+		WHERE c.session_id = ? AND c.number = ? AND s.status = ?
+	`, id, number, UploadOpen)
+
+	return err
+}
+
+// Claim marks an open session as being completed, so that chunks can't change while the file is
+// assembled and a concurrent completion fails. A session whose claim is older than UploadClaimTimeout
+// is claimed anew. The returned claim number is passed to Release and Complete, which fail once the
+// claim was taken over.
+func (m ModelUpload) Claim(ctx context.Context, id int64) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE upload_sessions SET
+			status = ?,
+			claim = claim + 1,
+			claimed_at = NOW()
+		WHERE id = ? AND (status = ? OR status = ? AND claimed_at <= NOW() - INTERVAL ? SECOND)
+	`, UploadCompleting, id, UploadOpen, UploadCompleting, int64(UploadClaimTimeout/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	if err = requireAffected(res); err == ErrNotFound {
+		return 0, ErrUploadState
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var claim int64
+	if err = tx.QueryRowContext(ctx, `SELECT claim FROM upload_sessions WHERE id = ?`, id).Scan(&claim); err != nil {
+		return 0, err
+	}
+
+	return claim, tx.Commit()
+}
+
+// settleClaim moves a session out of completing, failing with ErrUploadState when the claim was
+// taken over by another completion or the session was cancelled.
+func (m ModelUpload) settleClaim(ctx context.Context, id int64, claim int64, to string, fileID *int64) error {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE upload_sessions SET
+			status = ?,
+			file_id = COALESCE(?, file_id),
+			claimed_at = NULL
+		WHERE id = ? AND status = ? AND claim = ?
+	`, to, fileID, id, UploadCompleting, claim)
+	if err != nil {
+		return err
+	}
+
+	if err = requireAffected(res); err == ErrNotFound {
+		return ErrUploadState
+	}
+
+	return err
+}
+
+// Release reopens a session whose completion failed.
+func (m ModelUpload) Release(ctx context.Context, id int64, claim int64) error {
+	return m.settleClaim(ctx, id, claim, UploadOpen, nil)
+}
+
+// Complete marks a claimed session as completed into the file, and forgets its chunks.
+func (m ModelUpload) Complete(ctx context.Context, id int64, claim int64, fileID int64) error {
+	if err := m.settleClaim(ctx, id, claim, UploadCompleted, &fileID); err != nil {
+		return err
+	}
+
+	_, err := m.db.ExecContext(ctx, `DELETE FROM upload_chunks WHERE session_id = ?`, id)
+
+	return err
+}
+
+// Cancel deletes a session unless a completion holds it, failing with ErrUploadState then. A claim
+// older than UploadClaimTimeout doesn't hold it anymore.
+func (m ModelUpload) Cancel(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `
+		DELETE FROM upload_sessions
+		WHERE id = ? AND (status <> ? OR claimed_at <= NOW() - INTERVAL ? SECOND)
+	`, id, UploadCompleting, int64(UploadClaimTimeout/time.Second))
+	if err != nil {
+		return err
+	}
+
+	if err = requireAffected(res); err == ErrNotFound {
+		return ErrUploadState
+	}
+
+	return err
+}
+
+func selectExpiredUploads(ctx context.Context, db *sql.DB, limit int) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM upload_sessions WHERE expires_at <= NOW() ORDER BY id LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetExpired returns up to limit sessions whose time ran out, with the chunks they still hold.
+func (m ModelUpload) GetExpired(ctx context.Context, limit int) ([]UploadSession, error) {
+	ids, err := selectExpiredUploads(ctx, m.db, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]UploadSession, 0, len(ids))
+	for _, id := range ids {
+		chunks, err := selectChunks(ctx, m.db, id)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, UploadSession{ID: id, Chunks: chunks})
+	}
+
+	return list, nil
+}
+
+func (m ModelUpload) Delete(ctx context.Context, id int64) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM upload_sessions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
@@ -1,8 +1,8 @@
 package models
 
 import (
+	"context"
 	"database/sql"
-	"log"
 	"time"
 )
 
@@ -16,8 +16,8 @@ type User struct {
 }
 
 type Credentials struct {
-	UserName     string `json:"user_name"`
-	PasswordHash string `json:"password_hash"`
+	UserName string `json:"user_name"`
+	Password string `json:"password"`
 }
 
 type UserCreateInput struct {
@@ -32,24 +32,24 @@ type ModelUser struct {
 }
 
 type IUserGetter interface {
-	Get(id int64) User
+	Get(ctx context.Context, id int64) (User, error)
 }
 
 type IUserLister interface {
-	GetList(limit, offset int, search string) []User
-	Count() int
+	GetList(ctx context.Context, page PageRequest, search string) ([]User, PageInfo, error)
+	Count(ctx context.Context, search string) (int, error)
 }
 
 type IUserCreator interface {
-	Create(in UserCreateInput) int64
-	IsLoginFree(login string) bool
+	Create(ctx context.Context, in UserCreateInput) (int64, error)
+	IsLoginFree(ctx context.Context, login string) (bool, error)
 	IUserGetter
 }
 
-func (m ModelUser) Get(id int64) User {
+func (m ModelUser) Get(ctx context.Context, id int64) (User, error) {
 	user := User{}
 
-	row := m.db.QueryRow(`
+	row := m.db.QueryRowContext(ctx, `
 		SELECT
 			id,
 			user_name,
@@ -68,50 +68,87 @@ func (m ModelUser) Get(id int64) User {
 		&user.About,
 		&user.DateCreated)
 
+	if err == sql.ErrNoRows {
+		return User{}, ErrNotFound
+	}
 	if err != nil {
-		return User{}
+		return User{}, err
 	}
 
-	return user
+	return user, nil
 }
 
-func (m ModelUser) GetList(limit, offset int, search string) []User {
-	users := make([]User, 0)
+var userSortKeys = map[string]sortKey{
+	"created": {column: "id"},
+	"name":    {column: "user_name"},
+}
+
+func (m ModelUser) GetList(ctx context.Context, page PageRequest, search string) ([]User, PageInfo, error) {
+	k, err := newKeyset(page, userSortKeys, "created", "id")
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
 
-	rows, err := m.db.Query(`
+	cond, condArgs := k.where()
+	order, orderArgs := k.orderLimit()
+	args := append(append([]interface{}{"%" + search + "%"}, condArgs...), orderArgs...)
+
+	rows, err := m.db.QueryContext(ctx, `
 		SELECT
-		       id, user_name, full_name, avatar, about
+		       id, user_name, full_name, avatar, about, `+k.column()+`
 		FROM users
-		WHERE full_name LIKE ?
-		LIMIT ? OFFSET ?
-	`, "%"+search+"%", limit, offset)
-
+		WHERE full_name LIKE ? AND `+cond+`
+		`+order, args...)
 	if err != nil {
-		return users
+		return nil, PageInfo{}, err
 	}
 	defer rows.Close()
 
+	users := make([]User, 0)
+	values := make([]string, 0)
+	ids := make([]int64, 0)
+
 	for rows.Next() {
 		var user User
+		var value string
 
-		err = rows.Scan(&user.ID, &user.Name, &user.FullName, &user.Avatar, &user.About)
+		err = rows.Scan(&user.ID, &user.Name, &user.FullName, &user.Avatar, &user.About, &value)
 		if err != nil {
-			//
-			return users
+			return nil, PageInfo{}, err
 		}
 
 		users = append(users, user)
+		values = append(values, value)
+		ids = append(ids, int64(user.ID))
 	}
 
 	if err = rows.Err(); err != nil {
-		// log error
+		return nil, PageInfo{}, err
 	}
 
-	return users
+	n, info := k.finish(users, values, ids)
+
+	return users[:n], info, nil
 }
 
-func (m ModelUser) Create(in UserCreateInput) int64 {
-	stmt, err := m.db.Prepare(`
+// Create registers the user. The password must pass the same checks as a password change.
+func (m ModelUser) Create(ctx context.Context, in UserCreateInput) (int64, error) {
+	if err := validatePassword("password", in.Password); err != nil {
+		return 0, err
+	}
+
+	passwordHash, err := HashPassword(in.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
 		INSERT INTO users (
 			full_name,
 			user_name,
@@ -120,57 +157,48 @@ func (m ModelUser) Create(in UserCreateInput) int64 {
 			password_hash,
 			date_created
 		) VALUE (?, ?, ?, ?, ?, NOW())
-	`)
-
+	`, in.FullName, in.UserName, in.Avatar, in.About, passwordHash)
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	res, err := stmt.Exec(in.FullName, in.UserName, in.Avatar, in.About, in.PasswordHash)
+	id, err := res.LastInsertId()
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
-	lastID, err := res.LastInsertId()
-	if err != nil {
-		return 0
+	if err = syncFileRefs(ctx, tx, refUser, id, in.Avatar); err != nil {
+		return 0, err
 	}
 
-	return lastID
+	return id, tx.Commit()
 }
 
-func (m ModelUser) Count() int {
-	rows, err := m.db.Query(`SELECT COUNT(*) FROM users`)
-	if err != nil {
-		log.Println(err)
-		return 0
-	}
-	defer rows.Close()
-
+func (m ModelUser) Count(ctx context.Context, search string) (int, error) {
 	var count int
 
-	for rows.Next() {
-		if err := rows.Scan(&count); err != nil {
-			log.Println(err)
-			return 0
-		}
+	err := m.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE full_name LIKE ?`, "%"+search+"%").Scan(&count)
+	if err != nil {
+		return 0, err
 	}
 
-	return count
+	return count, nil
 }
 
-func (m ModelUser) IsLoginFree(login string) bool {
+func (m ModelUser) IsLoginFree(ctx context.Context, login string) (bool, error) {
 	var id int
 
-	row := m.db.QueryRow(`SELECT id FROM users WHERE user_name = ? `, login)
+	row := m.db.QueryRowContext(ctx, `SELECT id FROM users WHERE user_name = ? `, login)
 
 	err := row.Scan(&id)
-
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
 	if err != nil {
-		return true
+		return false, err
 	}
 
-	return id == 0
+	return id == 0, nil
 }
 
 func NewUserModel(db *sql.DB) ModelUser {
@@ -0,0 +1,281 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// getLessonAssignment loads the assignment from the :assignmentId route parameter and makes sure it belongs
+// to the lesson from :lessonId, which in turn must belong to the course from :id.
+func getLessonAssignment(c *gin.Context, lessons models.ILessonGetter, model models.IAssignmentGetter) (models.Assignment, bool) {
+	lesson, ok := getCourseLesson(c, lessons)
+	if !ok {
+		return models.Assignment{}, false
+	}
+
+	id, ok := paramID(c, "assignmentId")
+	if !ok {
+		return models.Assignment{}, false
+	}
+
+	assignment, err := model.Get(c.Request.Context(), id)
+	if err == nil && assignment.LessonID != lesson.ID {
+		err = models.ErrNotFound
+	}
+	if err != nil {
+		abortWithError(c, notFound(err, fmt.Sprintf("No assignment with id %d in lesson %d", id, lesson.ID)))
+		return models.Assignment{}, false
+	}
+
+	return assignment, true
+}
+
+// respondAssignment writes the stored assignment with the given id.
+func respondAssignment(c *gin.Context, model models.IAssignmentGetter, id int64, status int) {
+	assignment, err := model.Get(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(status, assignment)
+}
+
+func ListAssignments(lessons models.ILessonGetter, model models.IAssignmentLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), int64(lesson.ID))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"assignments": list})
+	}
+}
+
+func GetAssignment(lessons models.ILessonGetter, model models.IAssignmentGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, ok := getLessonAssignment(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, assignment)
+	}
+}
+
+func bindAssignment(c *gin.Context) (models.AssignmentInput, bool) {
+	inputData := models.AssignmentInput{}
+	if !bindJSON(c, &inputData) {
+		return inputData, false
+	}
+
+	if inputData.MaxScore <= 0 {
+		abortWithError(c, validationError("max_score must be positive", nil))
+		return inputData, false
+	}
+
+	return inputData, true
+}
+
+func CreateAssignment(lessons models.ILessonGetter, model models.IAssignmentCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		inputData, ok := bindAssignment(c)
+		if !ok {
+			return
+		}
+
+		id, err := model.Create(c.Request.Context(), int64(lesson.ID), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondAssignment(c, model, id, http.StatusCreated)
+	}
+}
+
+func UpdateAssignment(lessons models.ILessonGetter, model models.IAssignmentUpdater) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, ok := getLessonAssignment(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		inputData, ok := bindAssignment(c)
+		if !ok {
+			return
+		}
+
+		if err := model.Update(c.Request.Context(), int64(assignment.ID), inputData); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondAssignment(c, model, int64(assignment.ID), http.StatusOK)
+	}
+}
+
+func DeleteAssignment(lessons models.ILessonGetter, model models.IAssignmentDeleter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, ok := getLessonAssignment(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		if err := model.Delete(c.Request.Context(), int64(assignment.ID)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// SubmitAssignment stores the student's work, given as the URL of a file uploaded to /fs/files/ or as
+// the id of a file the student uploaded through /uploads/.
+func SubmitAssignment(lessons models.ILessonGetter, model models.ISubmissionCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireStudent(c) {
+			return
+		}
+
+		assignment, ok := getLessonAssignment(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		inputData := models.SubmissionInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		id, err := model.Submit(c.Request.Context(), assignment, courseAccess(c).UserID, inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		submission, err := model.GetSubmission(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, submission)
+	}
+}
+
+// reviewSubmission returns a handler that moves a submission of the assignment to the given status.
+func reviewSubmission(lessons models.ILessonGetter, model models.ISubmissionReviewer, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		assignment, ok := getLessonAssignment(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		id, ok := paramID(c, "submissionId")
+		if !ok {
+			return
+		}
+
+		submission, err := model.GetSubmission(c.Request.Context(), id)
+		if err == nil && submission.AssignmentID != assignment.ID {
+			err = models.ErrNotFound
+		}
+		if err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No submission with id %d for assignment %d", id, assignment.ID)))
+			return
+		}
+
+		inputData := models.ReviewInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err = model.SetReview(c.Request.Context(), submission, status, inputData); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		submission, err = model.GetSubmission(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, submission)
+	}
+}
+
+// ReviewSubmission grades a submission and closes it.
+func ReviewSubmission(lessons models.ILessonGetter, model models.ISubmissionReviewer) gin.HandlerFunc {
+	return reviewSubmission(lessons, model, models.SubmissionReviewed)
+}
+
+// ReturnSubmission sends a submission back to the student with feedback so they can submit again.
+func ReturnSubmission(lessons models.ILessonGetter, model models.ISubmissionReviewer) gin.HandlerFunc {
+	return reviewSubmission(lessons, model, models.SubmissionReturned)
+}
+
+// submissionStatus reads the optional status query parameter of the submission lists, aborting with
+// 400 when it isn't a status submissions can have.
+func submissionStatus(c *gin.Context) (string, bool) {
+	status := c.Query("status")
+	switch status {
+	case "", models.SubmissionSubmitted, models.SubmissionReviewed, models.SubmissionReturned:
+		return status, true
+	}
+
+	abortWithError(c, validationError("invalid status", status))
+	return "", false
+}
+
+// ListCourseSubmissions is the mentors' queue of submissions to every assignment of the course,
+// filtered by the optional status query parameter.
+func ListCourseSubmissions(model models.ISubmissionLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, ok := submissionStatus(c)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetCourseSubmissions(c.Request.Context(), courseAccess(c).CourseID, status)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"submissions": list})
+	}
+}
+
+// ListSelfSubmissions returns the caller's own submissions, filtered by the optional status query parameter.
+func ListSelfSubmissions(model models.ISubmissionLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, ok := submissionStatus(c)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetUserSubmissions(c.Request.Context(), selfID(c), status)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"submissions": list})
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ListCategories(model models.ICategoryLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := model.GetList(c.Request.Context())
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"categories": list})
+	}
+}
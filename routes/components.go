@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type componentOrderInput struct {
+	ComponentIDs []int64 `json:"component_ids" binding:"required"`
+}
+
+// getLessonComponent loads the component from the :componentId route parameter and makes sure it belongs
+// to the lesson from the :lessonId parameter, which in turn must belong to the course from :id.
+func getLessonComponent(c *gin.Context, lessons models.ILessonGetter, model models.IComponentGetter) (models.Component, bool) {
+	lesson, ok := getCourseLesson(c, lessons)
+	if !ok {
+		return models.Component{}, false
+	}
+
+	id, ok := paramID(c, "componentId")
+	if !ok {
+		return models.Component{}, false
+	}
+
+	component, err := model.Get(c.Request.Context(), id)
+	if err == nil && component.LessonID != lesson.ID {
+		err = models.ErrNotFound
+	}
+	if err != nil {
+		abortWithError(c, notFound(err, fmt.Sprintf("No component with id %d in lesson %d", id, lesson.ID)))
+		return models.Component{}, false
+	}
+
+	return component, true
+}
+
+// visibleComponents hides the answers of quiz components from callers who can't edit the lesson.
+func visibleComponents(c *gin.Context, list []models.Component) []models.Component {
+	if seesAnswers(c) {
+		return list
+	}
+
+	visible := make([]models.Component, len(list))
+	for i, component := range list {
+		visible[i] = component.WithoutAnswer()
+	}
+
+	return visible
+}
+
+// respondComponent writes the stored component with the given id.
+func respondComponent(c *gin.Context, model models.IComponentGetter, id int64, status int) {
+	component, err := model.Get(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(status, component)
+}
+
+func ListComponents(lessons models.ILessonGetter, model models.IComponentLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), int64(lesson.ID))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"components": visibleComponents(c, list)})
+	}
+}
+
+func GetComponent(lessons models.ILessonGetter, model models.IComponentGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		component, ok := getLessonComponent(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		if !seesAnswers(c) {
+			component = component.WithoutAnswer()
+		}
+
+		c.JSON(http.StatusOK, component)
+	}
+}
+
+func CreateComponent(lessons models.ILessonGetter, model models.IComponentCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		inputData := models.ComponentInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		var err error
+		inputData.Content, err = models.NormalizeComponent(inputData.Type, inputData.Content)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		id, err := model.Create(c.Request.Context(), int64(lesson.ID), selfID(c), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondComponent(c, model, id, http.StatusCreated)
+	}
+}
+
+func UpdateComponent(lessons models.ILessonGetter, model models.IComponentUpdater) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		component, ok := getLessonComponent(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		inputData := models.ComponentInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		var err error
+		component.Type = inputData.Type
+		component.Content, err = models.NormalizeComponent(inputData.Type, inputData.Content)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		if err = model.Update(c.Request.Context(), component, selfID(c)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondComponent(c, model, int64(component.ID), http.StatusOK)
+	}
+}
+
+func DeleteComponent(lessons models.ILessonGetter, model models.IComponentDeleter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		component, ok := getLessonComponent(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		if err := model.Delete(c.Request.Context(), int64(component.ID)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// ReorderComponents renumbers all components of the lesson in the order given by component_ids.
+func ReorderComponents(lessons models.ILessonGetter, model models.IComponentReorderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		inputData := componentOrderInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err := model.Reorder(c.Request.Context(), int64(lesson.ID), inputData.ComponentIDs); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), int64(lesson.ID))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"components": visibleComponents(c, list)})
+	}
+}
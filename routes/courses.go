@@ -2,76 +2,137 @@ package routes
 
 import (
 	"coursify-api/models"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
+// courseFilter reads the catalogue filters from the search, mentor, owner, enrolled, status, category
+// and tag query parameters. The tag parameter can be repeated.
+func courseFilter(c *gin.Context) (models.CourseFilter, bool) {
+	filter := models.CourseFilter{}
+
+	filter.Search, _ = url.QueryUnescape(c.DefaultQuery("search", ""))
+	filter.Search = strings.TrimSpace(filter.Search)
+
+	var ok bool
+	if filter.MentorID, ok = queryID(c, "mentor"); !ok {
+		return filter, false
+	}
+	if filter.OwnerID, ok = queryID(c, "owner"); !ok {
+		return filter, false
+	}
+
+	filter.Status = c.Query("status")
+	switch filter.Status {
+	case "", models.CourseDraft, models.CoursePublished, models.CourseArchived:
+	default:
+		abortWithError(c, validationError("invalid status", filter.Status))
+		return filter, false
+	}
+
+	filter.Category = c.Query("category")
+	filter.Tags = c.QueryArray("tag")
+
+	if value := c.Query("enrolled"); value != "" {
+		enrolled, err := strconv.ParseBool(value)
+		if err != nil {
+			abortWithError(c, validationError("enrolled must be true or false", value))
+			return filter, false
+		}
+		filter.Enrolled = &enrolled
+	}
+
+	return filter, true
+}
+
 func ListCourses(model models.ICourseLister) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
-		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-		listType := c.DefaultQuery("type", models.TypeAllCourses)
+		page, ok := pageRequest(c)
+		if !ok {
+			return
+		}
 
-		var list []models.CourseDetail
-		var total int
+		filter, ok := courseFilter(c)
+		if !ok {
+			return
+		}
 
-		any, _ := c.Get(gin.AuthUserKey)
-		selfID, _ := any.(int64)
+		switch c.DefaultQuery("type", models.TypeAllCourses) {
+		case models.TypeAllCourses:
+		case models.TypeAdminCourses:
+			filter.ManagerID = selfID(c)
+		default:
+			enrolled := true
+			filter.Enrolled = &enrolled
+		}
 
-		if listType == models.TypeAllCourses {
-			searchQuery := c.DefaultQuery("search", "")
-			decodedSearchQuery, _ := url.QueryUnescape(searchQuery)
+		list, info, err := model.GetList(c.Request.Context(), page, selfID(c), filter)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-			list = model.GetList(limit, offset, selfID, decodedSearchQuery)
-			total = model.Count()
-		} else {
-			list = model.GetListForUser(limit, offset, selfID, listType == models.TypeAdminCourses)
-			total = model.CountForUser(selfID, listType == models.TypeAdminCourses)
+		total, err := model.Count(c.Request.Context(), selfID(c), filter)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		facets, err := model.Facets(c.Request.Context(), selfID(c), filter)
+		if err != nil {
+			abortWithError(c, err)
+			return
 		}
 
+		meta := pageMeta(page, info, total)
+		meta["facets"] = facets
+
 		c.JSON(http.StatusOK, gin.H{
-			"meta": gin.H{
-				"limit":  limit,
-				"offset": offset,
-				"total":  total,
-			},
+			"meta":    meta,
 			"courses": list,
 		})
 	}
 }
 
-
+// EnterCourse enrolls the caller. It answers 200 when they became a student and 202 when their request
+// waits for approval or for a free seat.
 func EnterCourse(model models.ICourseGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
 
+		result, err := model.Enter(c.Request.Context(), id, selfID(c))
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			abortWithError(c, notFound(err, fmt.Sprintf("No course with id %d", id)))
+			return
 		}
 
-		any, _ := c.Get(gin.AuthUserKey)
-		selfID, _ := any.(int64)
-
-		model.Enter(id, selfID)
+		status := http.StatusOK
+		if result != models.EnrollmentEntered {
+			status = http.StatusAccepted
+		}
 
-		c.String(http.StatusOK, "")
+		c.JSON(status, gin.H{"status": result})
 	}
 }
 
 func LeaveCourse(model models.ICourseGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
 		}
 
-		any, _ := c.Get(gin.AuthUserKey)
-		selfID, _ := any.(int64)
-
-		model.Leave(id, selfID)
+		if err := model.Leave(c.Request.Context(), id, selfID(c)); err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.String(http.StatusOK, "")
 	}
@@ -80,72 +141,139 @@ func LeaveCourse(model models.ICourseGetter) gin.HandlerFunc {
 func CreateCourse(model models.ICourseCreator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		inputData := models.CourseCreateInput{}
-		err := c.ShouldBindJSON(&inputData)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		if !bindJSON(c, &inputData) {
+			return
 		}
 
-		any, _ := c.Get(gin.AuthUserKey)
-		selfID, _ := any.(int64)
+		id, err := model.Create(c.Request.Context(), inputData, selfID(c))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-		id := model.Create(inputData, selfID)
-		course := model.Get(id)
+		course, err := model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusCreated, course)
 	}
 }
 
-func GetCourse(model models.ICourseGetter) gin.HandlerFunc {
+func GetCourse(model models.ICourseGetter, progress models.IProgressGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
 
+		course, err := model.Get(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			abortWithError(c, notFound(err, fmt.Sprintf("No course with id %d", id)))
+			return
 		}
 
-		course := model.Get(id)
-		// TODO: check if exists
-		if course.ID == 0 {
-			c.String(http.StatusNotFound, "No course with id %d", id)
+		course.Entered, err = model.Entered(c.Request.Context(), id, selfID(c))
+		if err != nil {
+			abortWithError(c, err)
 			return
 		}
 
+		if course.Entered {
+			courseProgress, err := progress.GetCourseProgress(c.Request.Context(), id, selfID(c))
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			course.Progress = &courseProgress
+		}
+
 		c.JSON(http.StatusOK, course)
 	}
 }
 
 func UpdateCourse(model models.ICourseUpdater) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
+
+		course, err := model.Get(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			abortWithError(c, notFound(err, fmt.Sprintf("No course with id %d", id)))
+			return
 		}
+		ownerID, mentors, status := course.OwnerID, course.Mentors, course.Status
 
-		course := model.Get(id)
+		course.Mentors = nil
+		if !bindJSON(c, &course) {
+			return
+		}
 
-		// TODO: check if exists
+		// Only the owner may hand the course over to someone else or change its mentors.
+		// The status has its own endpoint.
+		course.ID, course.Status = id, status
+		if course.Mentors == nil || courseAccess(c).Level != models.LevelOwner {
+			course.Mentors = mentors
+		}
+		if courseAccess(c).Level != models.LevelOwner {
+			course.OwnerID = ownerID
+		}
 
-		err = c.ShouldBindJSON(&course)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		if err = model.Update(c.Request.Context(), course); err != nil {
+			abortWithError(c, err)
+			return
 		}
 
-		model.Update(course)
+		course, err = model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-		c.JSON(http.StatusOK, model.Get(id))
+		c.JSON(http.StatusOK, course)
 	}
 }
 
 func DeleteCourse(model models.ICourseDeleter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
 		}
 
-		// TODO: check if exists
-		model.Delete(id)
+		if err := model.Delete(c.Request.Context(), id); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No course with id %d", id)))
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{})
 	}
 }
+
+// SetCourseStatus publishes, archives or restores the course.
+func SetCourseStatus(model models.ICourseStatusEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := courseAccess(c).CourseID
+
+		inputData := models.CourseStatusInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err := model.SetStatus(c.Request.Context(), id, inputData.Status); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		course, err := model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, course)
+	}
+}
@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func ListEnrollments(model models.IEnrollmentManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := model.GetEnrollments(c.Request.Context(), courseAccess(c).CourseID, c.Query("status"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"enrollments": list})
+	}
+}
+
+// InviteStudent lets a user enter the course without approval, which is the only way into invite-only courses.
+func InviteStudent(model models.IEnrollmentManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.EnrollmentInviteInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err := model.InviteStudent(c.Request.Context(), courseAccess(c).CourseID, inputData.UserID, selfID(c)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"status": models.EnrollmentInvited})
+	}
+}
+
+func ApproveEnrollment(model models.IEnrollmentManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+
+		userID, ok := paramID(c, "userId")
+		if !ok {
+			return
+		}
+
+		result, err := model.Approve(c.Request.Context(), courseID, userID, selfID(c))
+		if err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("User %d has no pending enrollment in course %d", userID, courseID)))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": result})
+	}
+}
+
+func RejectEnrollment(model models.IEnrollmentManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+
+		userID, ok := paramID(c, "userId")
+		if !ok {
+			return
+		}
+
+		inputData := models.EnrollmentRejectInput{}
+		if c.Request.ContentLength != 0 && !bindJSON(c, &inputData) {
+			return
+		}
+
+		err := model.Reject(c.Request.Context(), courseID, userID, selfID(c), inputData.Reason)
+		if err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("User %d has no pending enrollment in course %d", userID, courseID)))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": models.EnrollmentRejected})
+	}
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"context"
+	"coursify-api/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Error codes returned in APIError.Code.
+const (
+	CodeValidation   = "validation_error"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeRefused      = "enrollment_refused"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeTimeout      = "timeout"
+	CodeInternal     = "internal_error"
+)
+
+// APIError is the body of every error response: {"error": {"code": ..., "message": ..., "details": ...}}.
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func validationError(message string, details interface{}) *APIError {
+	return &APIError{http.StatusBadRequest, CodeValidation, message, details}
+}
+
+func notFoundError(message string) *APIError {
+	return &APIError{http.StatusNotFound, CodeNotFound, message, nil}
+}
+
+func conflictError(message string) *APIError {
+	return &APIError{http.StatusConflict, CodeConflict, message, nil}
+}
+
+func forbiddenError(message string, details interface{}) *APIError {
+	return &APIError{http.StatusForbidden, CodeForbidden, message, details}
+}
+
+// abortWithError writes err as an APIError and stops the handler chain. Model errors are mapped
+// to their status: ErrNotFound to 404, validation errors to 400, conflicts and enrollment refusals to 409,
+// a cancelled or timed out request context to 504, anything else to 500.
+func abortWithError(c *gin.Context, err error) {
+	var apiErr *APIError
+	var validationErr models.ValidationError
+	var conflictErr models.ConflictError
+	var refusal models.EnrollmentRefusal
+
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, models.ErrNotFound):
+		apiErr = notFoundError("resource not found")
+	case errors.As(err, &validationErr):
+		apiErr = validationError(validationErr.Error(), nil)
+	case errors.As(err, &conflictErr):
+		apiErr = conflictError(conflictErr.Error())
+	case errors.As(err, &refusal):
+		apiErr = &APIError{http.StatusConflict, CodeRefused, refusal.Message, gin.H{"reason": refusal.Reason}}
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		apiErr = &APIError{http.StatusGatewayTimeout, CodeTimeout, "request timed out", nil}
+	default:
+		log.Println(err)
+		apiErr = &APIError{http.StatusInternalServerError, CodeInternal, "internal server error", nil}
+	}
+
+	c.AbortWithStatusJSON(apiErr.Status, gin.H{"error": apiErr})
+}
+
+// paramID parses the named route parameter as an id, aborting with 400 when it isn't one.
+func paramID(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		abortWithError(c, validationError("invalid "+name, c.Param(name)))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// queryID parses the named query parameter as an id, aborting with 400 when it isn't one.
+// A missing parameter reads as 0.
+func queryID(c *gin.Context, name string) (int64, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, true
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		abortWithError(c, validationError("invalid "+name, value))
+		return 0, false
+	}
+
+	return id, true
+}
+
+// bindJSON binds the request body into obj, aborting with 400 and the binding error as details on failure.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		abortWithError(c, validationError("invalid request body", err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// notFound replaces ErrNotFound with a not-found error carrying the given message.
+func notFound(err error, message string) error {
+	if errors.Is(err, models.ErrNotFound) {
+		return notFoundError(message)
+	}
+
+	return err
+}
+
+// selfID returns the id of the authenticated user.
+func selfID(c *gin.Context) int64 {
+	any, _ := c.Get(gin.AuthUserKey)
+	id, _ := any.(int64)
+
+	return id
+}
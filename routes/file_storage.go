@@ -1,28 +1,326 @@
 package routes
 
 import (
+	"bytes"
+	"coursify-api/imaging"
+	"coursify-api/models"
+	"coursify-api/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	guuid "github.com/google/uuid"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-func PostImageFile(dir string) gin.HandlerFunc {
+// MaxUploadSize caps the size of a file uploaded in one request.
+const MaxUploadSize = 50 << 20
+
+// ImageOptions configures image uploads. MaxSize caps the size of the uploaded file and every image
+// gets a thumbnail for each of ThumbnailSizes, fitting a square of that many pixels.
+type ImageOptions struct {
+	MaxSize        int64
+	ThumbnailSizes []int
+}
+
+var extensionPattern = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// imageExtensions maps the accepted image types to the extension their files are stored with.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// activeContentTypes are the types a browser runs or renders as a page. Uploads claiming one of them
+// are stored as plain bytes, so that the storage never serves a script from the API's origin.
+var activeContentTypes = map[string]bool{
+	"text/html":              true,
+	"application/xhtml+xml":  true,
+	"image/svg+xml":          true,
+	"text/xml":               true,
+	"application/xml":        true,
+	"text/javascript":        true,
+	"application/javascript": true,
+}
+
+// safeContentType returns contentType, or application/octet-stream for missing and active types.
+func safeContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || activeContentTypes[mediaType] {
+		return "application/octet-stream"
+	}
+
+	return contentType
+}
+
+// readUpload reads the request body, aborting with 400 when it is empty and with 413 when it is
+// larger than limit.
+func readUpload(c *gin.Context, limit int64) ([]byte, bool) {
+	tooLarge := &APIError{
+		http.StatusRequestEntityTooLarge, CodeValidation,
+		fmt.Sprintf("the request body can be at most %d bytes", limit), gin.H{"max_size": limit},
+	}
+	if c.Request.ContentLength > limit {
+		abortWithError(c, tooLarge)
+		return nil, false
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+	if err != nil {
+		abortWithError(c, validationError("could not read request body", err.Error()))
+		return nil, false
+	}
+	if int64(len(data)) > limit {
+		abortWithError(c, tooLarge)
+		return nil, false
+	}
+	if len(data) == 0 {
+		abortWithError(c, validationError("the request body is empty", nil))
+		return nil, false
+	}
+
+	return data, true
+}
+
+// newFileKey returns a random storage key under kind/.
+func newFileKey(kind string) string {
+	return kind + "/" + guuid.New().String()
+}
+
+// putFile puts data into the storage under file.Key and records its metadata.
+func putFile(c *gin.Context, store storage.Storage, files models.IFileCreator, file models.StoredFile, data []byte) (models.StoredFile, error) {
+	sum := sha256.Sum256(data)
+
+	file.Size = int64(len(data))
+	file.Checksum = hex.EncodeToString(sum[:])
+
+	err := store.Put(c.Request.Context(), file.Key, bytes.NewReader(data), file.Size, file.ContentType)
+	if err != nil {
+		return file, err
+	}
+
+	return recordFile(c, store, files, file)
+}
+
+// recordFile records the metadata of a file put into the storage, owned by the authenticated user.
+// The file is deleted from the storage again when that fails.
+func recordFile(c *gin.Context, store storage.Storage, files models.IFileCreator, file models.StoredFile) (models.StoredFile, error) {
+	file.URL = store.URL(file.Key)
+	if owner := selfID(c); owner != 0 {
+		file.OwnerID = &owner
+	}
+
+	var err error
+	if file.ID, err = files.Create(c.Request.Context(), file); err != nil {
+		_ = store.Delete(c.Request.Context(), file.Key)
+		return file, err
+	}
+
+	return file, nil
+}
+
+// fileExtension returns the extension of a file name if it looks like one, or "".
+func fileExtension(name string) string {
+	extension := filepath.Ext(name)
+	if !extensionPattern.MatchString(extension) {
+		return ""
+	}
+
+	return extension
+}
+
+// PostImageFile stores an image and its thumbnails. The type is sniffed from the content, which must
+// be a JPEG, PNG, GIF or WebP image that decodes, and the stored image has its metadata stripped.
+// The thumbnails are stored next to the image, e.g. images/<uuid>_256.jpg.
+func PostImageFile(store storage.Storage, files models.IFileCreator, opts ImageOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := readUpload(c, opts.MaxSize)
+		if !ok {
+			return
+		}
+
+		contentType := http.DetectContentType(data)
+		extension, ok := imageExtensions[contentType]
+		if !ok {
+			abortWithError(c, validationError("the file is not a JPEG, PNG, GIF or WebP image", contentType))
+			return
+		}
+
+		img, err := imaging.Normalize(data, contentType)
+		if err == imaging.ErrTooLarge {
+			abortWithError(c, validationError("the image is too large", gin.H{"max_pixels": imaging.MaxPixels}))
+			return
+		}
+		if err != nil {
+			abortWithError(c, validationError("the image could not be decoded", err.Error()))
+			return
+		}
+
+		name := newFileKey(models.FileKindImage)
+
+		file := models.StoredFile{
+			Key:         name + extension,
+			Kind:        models.FileKindImage,
+			ContentType: contentType,
+			Width:       &img.Width,
+			Height:      &img.Height,
+		}
+		if file, err = putFile(c, store, files, file, img.Data); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		// A failed thumbnail deletes the image and the thumbnails stored before it. Deleting the
+		// image's record deletes theirs as well.
+		stored := []string{file.Key}
+		discard := func(err error) {
+			for _, key := range stored {
+				_ = store.Delete(c.Request.Context(), key)
+			}
+			_ = files.Delete(c.Request.Context(), file.ID)
+			abortWithError(c, err)
+		}
+
+		thumbnails := make(map[string]string, len(opts.ThumbnailSizes))
+		for _, size := range opts.ThumbnailSizes {
+			thumb, err := img.Thumbnail(size)
+			if err != nil {
+				discard(err)
+				return
+			}
+
+			variant := models.StoredFile{
+				Key:         fmt.Sprintf("%s_%d%s", name, size, imageExtensions[thumb.ContentType]),
+				Kind:        models.FileKindImage,
+				ContentType: thumb.ContentType,
+				ParentID:    &file.ID,
+				Width:       &thumb.Width,
+				Height:      &thumb.Height,
+			}
+			if variant, err = putFile(c, store, files, variant, thumb.Data); err != nil {
+				discard(err)
+				return
+			}
+			stored = append(stored, variant.Key)
+
+			thumbnails[strconv.Itoa(size)] = variant.URL
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"id":         file.ID,
+			"url":        file.URL,
+			"width":      img.Width,
+			"height":     img.Height,
+			"thumbnails": thumbnails,
+		})
+	}
+}
+
+// PostFile stores an arbitrary file. The extension is taken from the optional name query parameter,
+// so that downloads keep a meaningful type, e.g. POST /fs/files/?name=report.pdf. Pages and scripts
+// are stored as application/octet-stream.
+func PostFile(store storage.Storage, files models.IFileCreator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		imageData, err := c.GetRawData()
+		data, ok := readUpload(c, MaxUploadSize)
+		if !ok {
+			return
+		}
+
+		name := c.Query("name")
+		if name != "" {
+			name = filepath.Base(name)
+		}
+
+		extension := fileExtension(name)
+		contentType := mime.TypeByExtension(extension)
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+		contentType = safeContentType(contentType)
+
+		file := models.StoredFile{
+			Key:          newFileKey(models.FileKindFile) + extension,
+			Kind:         models.FileKindFile,
+			ContentType:  contentType,
+			OriginalName: name,
+		}
+		file, err := putFile(c, store, files, file, data)
 		if err != nil {
-			c.String(http.StatusBadRequest, err.Error())
+			abortWithError(c, err)
+			return
 		}
 
-		name := guuid.New().String() + ".jpeg"
-		path := dir + "/images/" + name
+		c.String(http.StatusOK, file.URL)
+	}
+}
+
+// ServeStoredFiles serves the files of the local storage kept under dir, which holds the keys of
+// kind. Directories are not listed. Files are sent as downloads and never sniffed, and files handed
+// in with a submission are only sent to its author and the course's mentors, who are authenticated
+// by auth.
+func ServeStoredFiles(dir string, kind string, files models.IFileServer, courses models.ICourseAccessGetter, auth gin.HandlerFunc) gin.HandlerFunc {
+	server := http.StripPrefix("/fs/"+kind, http.FileServer(gin.Dir(dir, false)))
 
-		err = ioutil.WriteFile(path, imageData, 0777)
+	return func(c *gin.Context) {
+		key := kind + c.Param("filepath")
+		if strings.HasSuffix(key, "/") {
+			abortWithError(c, notFoundError("No such file"))
+			return
+		}
+
+		uses, err := files.GetSubmissionUses(c.Request.Context(), key)
 		if err != nil {
-			c.String(http.StatusInternalServerError, "")
+			abortWithError(c, err)
+			return
+		}
+
+		if len(uses) > 0 {
+			auth(c)
+			if c.IsAborted() {
+				return
+			}
+
+			if !mayDownload(c, courses, uses) {
+				abortWithError(c, notFoundError("No such file"))
+				return
+			}
+			c.Header("Cache-Control", "private")
 		}
 
-		imageURL := "http://localhost:8080/fs/images/" + name
-		c.String(http.StatusOK, imageURL)
+		c.Header("X-Content-Type-Options", "nosniff")
+		if kind == models.FileKindFile {
+			c.Header("Content-Disposition", "attachment")
+		}
+
+		server.ServeHTTP(c.Writer, c.Request)
 	}
 }
+
+// mayDownload reports whether the authenticated user wrote one of the submissions or may view the
+// work handed in on its course.
+func mayDownload(c *gin.Context, courses models.ICourseAccessGetter, uses []models.SubmissionUse) bool {
+	for _, use := range uses {
+		if use.UserID == selfID(c) {
+			return true
+		}
+
+		access, err := courses.GetAccess(c.Request.Context(), use.CourseID, selfID(c))
+		if err != nil {
+			continue
+		}
+		if ok, _ := access.Allows(models.PermissionViewWork); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// respondInvitation writes the stored invitation with the given id.
+func respondInvitation(c *gin.Context, model models.IInvitationGetter, id int64, status int) {
+	invitation, err := model.Get(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(status, invitation)
+}
+
+func ListCourseInvitations(model models.IInvitationEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := model.GetCourseList(c.Request.Context(), courseAccess(c).CourseID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invitations": list})
+	}
+}
+
+// InviteMentor invites a user to mentor the course. They become a mentor once they accept.
+func InviteMentor(model models.IInvitationEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.MentorInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		id, err := model.Invite(c.Request.Context(), courseAccess(c).CourseID, selfID(c), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondInvitation(c, model, id, http.StatusCreated)
+	}
+}
+
+func RevokeInvitation(model models.IInvitationEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+
+		id, ok := paramID(c, "invitationId")
+		if !ok {
+			return
+		}
+
+		if err := model.Revoke(c.Request.Context(), courseID, id); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No invitation with id %d in course %d", id, courseID)))
+			return
+		}
+
+		respondInvitation(c, model, id, http.StatusOK)
+	}
+}
+
+func ListSelfInvitations(model models.IInvitationResponder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := model.GetUserList(c.Request.Context(), selfID(c))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"invitations": list})
+	}
+}
+
+func AcceptInvitation(model models.IInvitationResponder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := paramID(c, "invitationId")
+		if !ok {
+			return
+		}
+
+		if err := model.Accept(c.Request.Context(), id, selfID(c)); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No invitation with id %d", id)))
+			return
+		}
+
+		respondInvitation(c, model, id, http.StatusOK)
+	}
+}
+
+func DeclineInvitation(model models.IInvitationResponder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := paramID(c, "invitationId")
+		if !ok {
+			return
+		}
+
+		if err := model.Decline(c.Request.Context(), id, selfID(c)); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No invitation with id %d", id)))
+			return
+		}
+
+		respondInvitation(c, model, id, http.StatusOK)
+	}
+}
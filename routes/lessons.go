@@ -5,25 +5,67 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"strconv"
 )
 
+type lessonOrderInput struct {
+	LessonIDs []int64 `json:"lesson_ids" binding:"required"`
+}
+
+// getCourseLesson loads the lesson from the :lessonId route parameter and makes sure it belongs
+// to the course from the :id parameter. It writes the error response itself and returns false on failure.
+func getCourseLesson(c *gin.Context, model models.ILessonGetter) (models.Lesson, bool) {
+	courseID, ok := paramID(c, "id")
+	if !ok {
+		return models.Lesson{}, false
+	}
+
+	id, ok := paramID(c, "lessonId")
+	if !ok {
+		return models.Lesson{}, false
+	}
+
+	lesson, err := model.Get(c.Request.Context(), id)
+	if err == nil && int64(lesson.CourseID) != courseID {
+		err = models.ErrNotFound
+	}
+	if err != nil {
+		abortWithError(c, notFound(err, fmt.Sprintf("No lesson with id %d in course %d", id, courseID)))
+		return models.Lesson{}, false
+	}
+
+	return lesson, true
+}
+
 func ListLessons(model models.ILessonLister) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
-		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-		courseID, err := strconv.ParseInt(c.Query("courseId"), 10, 64)
+		courseID, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
+
+		page, ok := pageRequest(c)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), courseID, page.Limit, page.Offset)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			abortWithError(c, err)
+			return
 		}
 
-		list := model.GetList(courseID, limit, offset)
+		total, err := model.Count(c.Request.Context(), courseID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"lessons": list,
 			"meta": gin.H{
-				"limit":  limit,
-				"offset": offset,
+				"limit":  page.Limit,
+				"offset": page.Offset,
+				"total":  total,
 			},
 		})
 	}
@@ -31,19 +73,29 @@ func ListLessons(model models.ILessonLister) gin.HandlerFunc {
 
 func CreateLesson(model models.ILessonCreator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		courseID, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
+
 		inputData := models.LessonCreateInput{}
-		err := c.ShouldBindJSON(&inputData)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		if !bindJSON(c, &inputData) {
 			return
 		}
 
-		// TODO: check if course exits
+		inputData.CourseID = int(courseID)
 
-		fmt.Println(inputData)
+		id, err := model.Create(c.Request.Context(), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-		id := model.Create(inputData)
-		lesson := model.Get(id)
+		lesson, err := model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusCreated, lesson)
 	}
@@ -51,49 +103,87 @@ func CreateLesson(model models.ILessonCreator) gin.HandlerFunc {
 
 func GetLesson(model models.ILessonGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		lesson, ok := getCourseLesson(c, model)
+		if !ok {
+			return
 		}
 
-		course := model.Get(id)
-		// TODO: check if exists
+		lesson.Components = visibleComponents(c, lesson.Components)
 
-		c.JSON(http.StatusOK, course)
+		c.JSON(http.StatusOK, lesson)
 	}
 }
 
 func UpdateLesson(model models.ILessonUpdater) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		lesson, ok := getCourseLesson(c, model)
+		if !ok {
+			return
+		}
+		id, courseID, number := lesson.ID, lesson.CourseID, lesson.Number
+
+		if !bindJSON(c, &lesson) {
+			return
 		}
 
-		lesson := model.Get(id)
-		// TODO: check if exists
+		// Lessons can't be moved between courses here, and their position is changed by ReorderLessons.
+		lesson.ID, lesson.CourseID, lesson.Number = id, courseID, number
 
-		err = c.ShouldBindJSON(&lesson)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		if err := model.Update(c.Request.Context(), lesson); err != nil {
+			abortWithError(c, err)
+			return
 		}
 
-		model.Update(lesson)
+		lesson, err := model.Get(c.Request.Context(), int64(id))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-		c.JSON(http.StatusOK, model.Get(id))
+		c.JSON(http.StatusOK, lesson)
 	}
 }
 
 func DeleteLesson(model models.ILessonDeleter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+		lesson, ok := getCourseLesson(c, model)
+		if !ok {
+			return
 		}
 
-		// TODO: check if exists
-		model.Delete(id)
+		if err := model.Delete(c.Request.Context(), int64(lesson.ID)); err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{})
 	}
 }
+
+// ReorderLessons renumbers all lessons of the course in the order given by lesson_ids.
+func ReorderLessons(model models.ILessonReorderer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
+
+		inputData := lessonOrderInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err := model.Reorder(c.Request.Context(), courseID, inputData.LessonIDs); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), courseID, len(inputData.LessonIDs), 0)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"lessons": list})
+	}
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func RemoveMentor(model models.ICourseMentorEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+
+		userID, ok := paramID(c, "userId")
+		if !ok {
+			return
+		}
+
+		if err := model.RemoveMentor(c.Request.Context(), courseID, userID); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("User %d is not a mentor of course %d", userID, courseID)))
+			return
+		}
+
+		mentors, err := model.GetMentorsList(c.Request.Context(), courseID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mentors": mentors})
+	}
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// pageRequest reads the limit, offset, sort and cursor query parameters. Limits above
+// models.MaxPageLimit are lowered to it.
+func pageRequest(c *gin.Context) (models.PageRequest, bool) {
+	page := models.PageRequest{
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	if page.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "5")); err != nil || page.Limit < 1 {
+		abortWithError(c, validationError("limit must be a positive number", c.Query("limit")))
+		return page, false
+	}
+	if page.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || page.Offset < 0 {
+		abortWithError(c, validationError("offset must be a non-negative number", c.Query("offset")))
+		return page, false
+	}
+
+	if page.Limit > models.MaxPageLimit {
+		page.Limit = models.MaxPageLimit
+	}
+	if page.Cursor != "" {
+		page.Offset = 0
+	}
+
+	return page, true
+}
+
+// pageMeta is the meta block of a list response. Missing cursors are written as null.
+func pageMeta(page models.PageRequest, info models.PageInfo, total int) gin.H {
+	meta := gin.H{
+		"limit":  page.Limit,
+		"offset": page.Offset,
+		"total":  total,
+		"next":   nil,
+		"prev":   nil,
+	}
+
+	if info.Next != "" {
+		meta["next"] = info.Next
+	}
+	if info.Prev != "" {
+		meta["prev"] = info.Prev
+	}
+
+	return meta
+}
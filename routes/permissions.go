@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+)
+
+// CourseAccessKey is the context key under which RequireCoursePermission stores the caller's models.CourseAccess.
+const CourseAccessKey = "course_access"
+
+// RequireCoursePermission returns a middleware that loads the caller's access to the course from the :id
+// route parameter and aborts with 403 and a structured reason when it doesn't grant the permission.
+// Drafts the caller can't see answer 404 as if they didn't exist.
+func RequireCoursePermission(model models.ICourseAccessGetter, permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := paramID(c, "id")
+		if !ok {
+			return
+		}
+
+		access, err := model.GetAccess(c.Request.Context(), id, selfID(c))
+		if err == nil && !access.Visible() {
+			err = models.ErrNotFound
+		}
+		if err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No course with id %d", id)))
+			return
+		}
+
+		if ok, reason := access.Allows(permission); !ok {
+			abortWithError(c, forbiddenError(reason, gin.H{
+				"permission": permission.Name,
+				"level":      access.Level.String(),
+				"role":       access.Role,
+			}))
+			return
+		}
+
+		c.Set(CourseAccessKey, access)
+	}
+}
+
+// courseAccess returns the access stored by RequireCoursePermission.
+func courseAccess(c *gin.Context) models.CourseAccess {
+	any, _ := c.Get(CourseAccessKey)
+	access, _ := any.(models.CourseAccess)
+
+	return access
+}
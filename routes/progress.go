@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// requireStudent aborts with 403 unless the caller is enrolled in the course as a student.
+func requireStudent(c *gin.Context) bool {
+	if level := courseAccess(c).Level; level != models.LevelStudent {
+		abortWithError(c, forbiddenError("only students enrolled in the course can do this", gin.H{
+			"level": level.String(),
+		}))
+		return false
+	}
+
+	return true
+}
+
+func StartLesson(lessons models.ILessonGetter, model models.IProgressTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireStudent(c) {
+			return
+		}
+
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		lessonProgress, err := model.StartLesson(c.Request.Context(), courseAccess(c).UserID, int64(lesson.ID))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, lessonProgress)
+	}
+}
+
+func CompleteLesson(lessons models.ILessonGetter, model models.IProgressTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireStudent(c) {
+			return
+		}
+
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		access := courseAccess(c)
+
+		lessonProgress, err := model.CompleteLesson(c.Request.Context(), access.UserID, int64(lesson.ID), access.CourseID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		courseProgress, err := model.GetCourseProgress(c.Request.Context(), access.CourseID, access.UserID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"lesson": lessonProgress,
+			"course": courseProgress,
+		})
+	}
+}
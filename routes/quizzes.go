@@ -0,0 +1,287 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type attemptInput struct {
+	Responses []models.QuestionResponse `json:"responses" binding:"required"`
+}
+
+// getLessonQuiz loads the quiz from the :quizId route parameter and makes sure it belongs to the lesson
+// from :lessonId, which in turn must belong to the course from :id.
+func getLessonQuiz(c *gin.Context, lessons models.ILessonGetter, model models.IQuizGetter) (models.Quiz, bool) {
+	lesson, ok := getCourseLesson(c, lessons)
+	if !ok {
+		return models.Quiz{}, false
+	}
+
+	id, ok := paramID(c, "quizId")
+	if !ok {
+		return models.Quiz{}, false
+	}
+
+	quiz, err := model.Get(c.Request.Context(), id)
+	if err == nil && quiz.LessonID != lesson.ID {
+		err = models.ErrNotFound
+	}
+	if err != nil {
+		abortWithError(c, notFound(err, fmt.Sprintf("No quiz with id %d in lesson %d", id, lesson.ID)))
+		return models.Quiz{}, false
+	}
+
+	return quiz, true
+}
+
+// seesAnswers reports whether the caller may see the correct answers of quizzes, which only those who
+// can edit the lesson do.
+func seesAnswers(c *gin.Context) bool {
+	ok, _ := courseAccess(c).Allows(models.PermissionEditLessons)
+
+	return ok
+}
+
+// visibleQuiz hides correct answers from callers who can't edit the lesson.
+func visibleQuiz(c *gin.Context, quiz models.Quiz) models.Quiz {
+	if seesAnswers(c) {
+		return quiz
+	}
+
+	questions := make([]models.Question, len(quiz.Questions))
+	for i, q := range quiz.Questions {
+		q.Answer = nil
+		questions[i] = q
+	}
+	quiz.Questions = questions
+
+	return quiz
+}
+
+// bindQuiz binds and validates a quiz from the request body.
+func bindQuiz(c *gin.Context) (models.QuizInput, bool) {
+	inputData := models.QuizInput{}
+	if !bindJSON(c, &inputData) {
+		return inputData, false
+	}
+
+	if err := models.ValidateQuiz(&inputData); err != nil {
+		abortWithError(c, err)
+		return inputData, false
+	}
+
+	return inputData, true
+}
+
+// respondQuiz writes the stored quiz with the given id.
+func respondQuiz(c *gin.Context, model models.IQuizGetter, id int64, status int) {
+	quiz, err := model.Get(c.Request.Context(), id)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(status, quiz)
+}
+
+func ListQuizzes(lessons models.ILessonGetter, model models.IQuizLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		list, err := model.GetList(c.Request.Context(), int64(lesson.ID))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		for i := range list {
+			list[i] = visibleQuiz(c, list[i])
+		}
+
+		c.JSON(http.StatusOK, gin.H{"quizzes": list})
+	}
+}
+
+func GetQuiz(lessons models.ILessonGetter, model models.IQuizGetter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, visibleQuiz(c, quiz))
+	}
+}
+
+func CreateQuiz(lessons models.ILessonGetter, model models.IQuizCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lesson, ok := getCourseLesson(c, lessons)
+		if !ok {
+			return
+		}
+
+		inputData, ok := bindQuiz(c)
+		if !ok {
+			return
+		}
+
+		id, err := model.Create(c.Request.Context(), int64(lesson.ID), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondQuiz(c, model, id, http.StatusCreated)
+	}
+}
+
+func UpdateQuiz(lessons models.ILessonGetter, model models.IQuizUpdater) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		inputData, ok := bindQuiz(c)
+		if !ok {
+			return
+		}
+
+		if err := model.Update(c.Request.Context(), int64(quiz.ID), inputData); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		respondQuiz(c, model, int64(quiz.ID), http.StatusOK)
+	}
+}
+
+func DeleteQuiz(lessons models.ILessonGetter, model models.IQuizDeleter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		if err := model.Delete(c.Request.Context(), int64(quiz.ID)); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// SubmitAttempt grades the student's responses and stores the attempt. A passed quiz that counts toward
+// progress completes its lesson.
+func SubmitAttempt(lessons models.ILessonGetter, model models.IAttemptCreator, progress models.IProgressTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireStudent(c) {
+			return
+		}
+
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		inputData := attemptInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		access := courseAccess(c)
+
+		attempt := models.Grade(quiz, inputData.Responses)
+		attempt.UserID = int(access.UserID)
+
+		id, err := model.CreateAttempt(c.Request.Context(), attempt)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		attempt.ID = int(id)
+
+		if attempt.Passed && quiz.CountsTowardProgress {
+			if _, err = progress.CompleteLesson(c.Request.Context(), access.UserID, int64(quiz.LessonID), access.CourseID); err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusCreated, attempt)
+	}
+}
+
+// ListAttempts returns the caller's own attempts. Mentors see every student's attempts,
+// optionally filtered by the user_id query parameter.
+func ListAttempts(lessons models.ILessonGetter, model models.IAttemptLister) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		access := courseAccess(c)
+		userID := access.UserID
+
+		if ok, _ := access.Allows(models.PermissionEditLessons); ok {
+			var err error
+			if userID, err = strconv.ParseInt(c.DefaultQuery("user_id", "0"), 10, 64); err != nil || userID < 0 {
+				abortWithError(c, validationError("user_id must be the id of a user", c.Query("user_id")))
+				return
+			}
+		}
+
+		list, err := model.GetAttempts(c.Request.Context(), int64(quiz.ID), userID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"attempts": list})
+	}
+}
+
+// ReviewAttempt lets a mentor grade the free-text responses of an attempt that wait for review. Once
+// none is left, an attempt that passes a quiz counting toward progress completes the student's lesson.
+func ReviewAttempt(lessons models.ILessonGetter, model models.IAttemptReviewer, progress models.IProgressTracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quiz, ok := getLessonQuiz(c, lessons, model)
+		if !ok {
+			return
+		}
+
+		id, ok := paramID(c, "attemptId")
+		if !ok {
+			return
+		}
+
+		inputData := models.AttemptReviewInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		attempt, err := model.ReviewAttempt(c.Request.Context(), quiz, id, inputData.Grades)
+		if err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No attempt with id %d on quiz %d", id, quiz.ID)))
+			return
+		}
+
+		if attempt.Passed && quiz.CountsTowardProgress {
+			_, err = progress.CompleteLesson(c.Request.Context(), int64(attempt.UserID), int64(quiz.LessonID), courseAccess(c).CourseID)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, attempt)
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func ListStudents(model models.IRosterManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+
+		page, ok := pageRequest(c)
+		if !ok {
+			return
+		}
+		search, _ := url.QueryUnescape(c.DefaultQuery("search", ""))
+
+		list, info, err := model.GetRoster(c.Request.Context(), courseID, page, search)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		total, err := model.CountRoster(c.Request.Context(), courseID, search)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"meta":     pageMeta(page, info, total),
+			"students": list,
+		})
+	}
+}
+
+// csvCell keeps a spreadsheet from running a cell as a formula by prefixing values that start
+// like one with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// ExportStudents writes the whole roster of the course as CSV, reading it a page at a time.
+func ExportStudents(model models.IRosterManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		courseID := courseAccess(c).CourseID
+		page := models.PageRequest{Limit: models.MaxPageLimit, Sort: "name"}
+
+		list, info, err := model.GetRoster(c.Request.Context(), courseID, page, "")
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="course-%d-students.csv"`, courseID))
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"user_id", "user_name", "full_name", "progress", "date_entered", "last_activity"})
+
+		for {
+			for _, entry := range list {
+				lastActivity := ""
+				if entry.LastActivity != nil {
+					lastActivity = entry.LastActivity.Format(time.RFC3339)
+				}
+
+				_ = w.Write([]string{
+					strconv.Itoa(entry.User.ID),
+					csvCell(entry.User.Name),
+					csvCell(entry.User.FullName),
+					strconv.FormatFloat(entry.Progress, 'f', 2, 64),
+					entry.DateEntered.Format(time.RFC3339),
+					lastActivity,
+				})
+			}
+
+			if info.Next == "" {
+				break
+			}
+
+			page.Cursor = info.Next
+			if list, info, err = model.GetRoster(c.Request.Context(), courseID, page, ""); err != nil {
+				// The status line is already out, so all that is left is to cut the file short.
+				_ = c.Error(err)
+				break
+			}
+		}
+
+		w.Flush()
+	}
+}
+
+// EnrollStudents enrolls users by user name, skipping those that can't be enrolled.
+func EnrollStudents(model models.IRosterManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.BulkEnrollInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		result, err := model.EnrollUsers(c.Request.Context(), courseAccess(c).CourseID, inputData.UserNames)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func RemoveStudent(model models.IRosterManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := paramID(c, "userId")
+		if !ok {
+			return
+		}
+
+		courseID := courseAccess(c).CourseID
+		if err := model.RemoveStudent(c.Request.Context(), courseID, userID); err != nil {
+			abortWithError(c, notFound(err, fmt.Sprintf("No student with id %d in course %d", userID, courseID)))
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
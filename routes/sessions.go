@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"coursify-api/models"
+	"coursify-api/tokens"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+type refreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// issueTokens creates a new access/refresh token pair for userID and writes it to the response.
+func issueTokens(c *gin.Context, model models.ISessionCreator, issuer *tokens.Issuer, userID int64) {
+	accessToken, accessExpires, err := issuer.AccessToken(userID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	refreshToken, refreshExpires, err := issuer.RefreshToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if _, err = model.CreateRefreshToken(c.Request.Context(), userID, tokens.Hash(refreshToken), refreshExpires); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token_type":         "Bearer",
+		"access_token":       accessToken,
+		"access_expires_at":  accessExpires,
+		"refresh_token":      refreshToken,
+		"refresh_expires_at": refreshExpires,
+	})
+}
+
+// LogInUser issues a token pair to a user authenticated with Basic credentials.
+func LogInUser(model models.ISessionCreator, issuer *tokens.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		issueTokens(c, model, issuer, selfID(c))
+	}
+}
+
+// LogOutUser revokes the given refresh token, or every refresh token of the user when the request has
+// no body. Any other body must name a refresh token. Access tokens are not tracked and stay valid until
+// they expire.
+func LogOutUser(model models.ISessionRevoker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := refreshInput{}
+
+		err := c.ShouldBindJSON(&inputData)
+		switch {
+		case err == io.EOF:
+			err = model.RevokeUserRefreshTokens(c.Request.Context(), selfID(c))
+		case err != nil:
+			abortWithError(c, validationError("invalid request body", err.Error()))
+			return
+		default:
+			err = model.RevokeRefreshToken(c.Request.Context(), selfID(c), tokens.Hash(inputData.RefreshToken))
+		}
+
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.String(http.StatusOK, "")
+	}
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair; the old refresh token is revoked.
+func RefreshToken(model models.ISessionRefresher, issuer *tokens.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := refreshInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		userID, err := model.UseRefreshToken(c.Request.Context(), tokens.Hash(inputData.RefreshToken))
+		if err == models.ErrNotFound {
+			abortWithError(c, &APIError{http.StatusUnauthorized, CodeUnauthorized, "Refresh token is invalid or expired", nil})
+			return
+		}
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		issueTokens(c, model, issuer, userID)
+	}
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// untimedKey holds the request context as it was before RequestTimeout bounded it.
+type untimedKey struct{}
+
+// RequestTimeout bounds the request context by the given duration, so that model queries
+// started by the handlers are cancelled once it runs out or the client goes away. Used again
+// further down the chain, it replaces the earlier bound, so routes that move large bodies can
+// be given more time.
+func RequestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := c.Request.Context()
+		if untimed, ok := parent.Value(untimedKey{}).(context.Context); ok {
+			parent = untimed
+		}
+
+		ctx, cancel := context.WithTimeout(context.WithValue(parent, untimedKey{}, parent), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
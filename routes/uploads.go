@@ -0,0 +1,289 @@
+package routes
+
+import (
+	"bytes"
+	"coursify-api/models"
+	"coursify-api/storage"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// chunkReader reads the chunks of an upload session one after the other, opening each when it gets to it.
+type chunkReader struct {
+	c       *gin.Context
+	store   storage.Storage
+	session models.UploadSession
+	number  int
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.number >= r.session.ChunkCount {
+				return 0, io.EOF
+			}
+			r.number++
+
+			var err error
+			if r.current, err = r.store.Open(r.c.Request.Context(), models.UploadChunkKey(r.session.ID, r.number)); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+
+	return r.current.Close()
+}
+
+// deleteChunks removes the stored chunks of an upload session. Chunks that fail to be deleted are
+// left behind.
+func deleteChunks(c *gin.Context, store storage.Storage, session models.UploadSession) {
+	for _, chunk := range session.Chunks {
+		_ = store.Delete(c.Request.Context(), models.UploadChunkKey(session.ID, chunk.Number))
+	}
+}
+
+// getOwnUpload loads the upload session of the uploadId parameter, aborting with 404 when it doesn't
+// exist, expired or was started by another user.
+func getOwnUpload(c *gin.Context, model models.IUploadManager) (models.UploadSession, bool) {
+	id, ok := paramID(c, "uploadId")
+	if !ok {
+		return models.UploadSession{}, false
+	}
+
+	session, err := model.Get(c.Request.Context(), id)
+	if err == nil && session.OwnerID != selfID(c) {
+		err = models.ErrNotFound
+	}
+	if err != nil {
+		abortWithError(c, notFound(err, fmt.Sprintf("No upload with id %d", id)))
+		return models.UploadSession{}, false
+	}
+
+	return session, true
+}
+
+// CreateUpload starts an upload session for a file too large to be sent in one request.
+func CreateUpload(model models.IUploadManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.UploadInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		inputData.Name = filepath.Base(inputData.Name)
+
+		id, err := model.Create(c.Request.Context(), selfID(c), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		session, err := model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, session)
+	}
+}
+
+// GetUpload returns the upload session with the chunks received so far and those still missing.
+func GetUpload(model models.IUploadManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := getOwnUpload(c, model)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// PutUploadChunk stores the numbered chunk sent as the raw request body. Every chunk but the last
+// must be exactly the session's chunk size. Sending a chunk again replaces it.
+func PutUploadChunk(store storage.Storage, model models.IUploadManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := getOwnUpload(c, model)
+		if !ok {
+			return
+		}
+		if session.Status != models.UploadOpen {
+			abortWithError(c, models.ErrUploadState)
+			return
+		}
+
+		number, err := strconv.Atoi(c.Param("number"))
+		size := session.ChunkSizeOf(number)
+		if err != nil || size == 0 {
+			abortWithError(c, validationError(
+				fmt.Sprintf("chunk number must be between 1 and %d", session.ChunkCount), c.Param("number"),
+			))
+			return
+		}
+
+		data, ok := readUpload(c, size)
+		if !ok {
+			return
+		}
+		if int64(len(data)) != size {
+			abortWithError(c, validationError(
+				fmt.Sprintf("chunk %d must be %d bytes long", number, size), gin.H{"size": len(data)},
+			))
+			return
+		}
+
+		sum := sha256.Sum256(data)
+		chunk := models.UploadChunk{Number: number, Size: size, Checksum: hex.EncodeToString(sum[:])}
+
+		// Recording the chunk first checks that the session is still open, so that a chunk is never
+		// written while a completion reads the chunks.
+		if err = model.AddChunk(c.Request.Context(), session.ID, chunk); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		err = store.Put(c.Request.Context(), models.UploadChunkKey(session.ID, number), bytes.NewReader(data), size, "application/octet-stream")
+		if err != nil {
+			_ = model.DropChunk(c.Request.Context(), session.ID, number)
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, chunk)
+	}
+}
+
+// CompleteUpload assembles the received chunks into a stored file, verifies the checksum given when
+// the upload was started and responds with the file, whose id can be used in lesson components and
+// assignment submissions. Completing a completed upload responds with its file again.
+func CompleteUpload(store storage.Storage, model models.IUploadManager, files models.IFileCreator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := getOwnUpload(c, model)
+		if !ok {
+			return
+		}
+
+		if session.Status == models.UploadCompleted && session.FileID != nil {
+			file, err := files.Get(c.Request.Context(), *session.FileID)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+
+			c.JSON(http.StatusOK, file)
+			return
+		}
+
+		if len(session.Missing) > 0 {
+			abortWithError(c, validationError("some chunks were not received yet", gin.H{"missing": session.Missing}))
+			return
+		}
+
+		claim, err := model.Claim(c.Request.Context(), session.ID)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		extension := fileExtension(session.Name)
+		contentType := session.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(extension)
+		}
+		contentType = safeContentType(contentType)
+
+		file := models.StoredFile{
+			Key:          newFileKey(models.FileKindFile) + extension,
+			Kind:         models.FileKindFile,
+			ContentType:  contentType,
+			OriginalName: session.Name,
+			Size:         session.Size,
+		}
+
+		file, err = assembleUpload(c, store, files, session, file)
+		if err != nil {
+			_ = model.Release(c.Request.Context(), session.ID, claim)
+			abortWithError(c, err)
+			return
+		}
+
+		if err = model.Complete(c.Request.Context(), session.ID, claim, file.ID); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		deleteChunks(c, store, session)
+
+		c.JSON(http.StatusCreated, file)
+	}
+}
+
+// assembleUpload streams the chunks of the session into the storage as file and records it, unless
+// the checksum of the whole doesn't match the session's.
+func assembleUpload(c *gin.Context, store storage.Storage, files models.IFileCreator, session models.UploadSession, file models.StoredFile) (models.StoredFile, error) {
+	chunks := &chunkReader{c: c, store: store, session: session}
+	defer chunks.Close()
+
+	hash := sha256.New()
+	err := store.Put(c.Request.Context(), file.Key, io.TeeReader(chunks, hash), file.Size, file.ContentType)
+	if err != nil {
+		_ = store.Delete(c.Request.Context(), file.Key)
+		return file, err
+	}
+
+	file.Checksum = hex.EncodeToString(hash.Sum(nil))
+	if file.Checksum != session.Checksum {
+		_ = store.Delete(c.Request.Context(), file.Key)
+		return file, validationError("the checksum of the uploaded file does not match", gin.H{
+			"expected": session.Checksum,
+			"actual":   file.Checksum,
+		})
+	}
+
+	return recordFile(c, store, files, file)
+}
+
+// CancelUpload abandons an upload session and deletes its chunks. A completed upload's file is kept.
+// A session being completed can't be cancelled until its claim times out.
+func CancelUpload(store storage.Storage, model models.IUploadManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := getOwnUpload(c, model)
+		if !ok {
+			return
+		}
+
+		if err := model.Cancel(c.Request.Context(), session.ID); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		deleteChunks(c, store, session)
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
@@ -2,63 +2,168 @@ package routes
 
 import (
 	"coursify-api/models"
+	"coursify-api/tokens"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"net/url"
-	"strconv"
 )
 
 func RegisterUser(model models.IUserCreator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		inputData := models.UserCreateInput{}
-		err := c.ShouldBindJSON(&inputData)
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if inputData.UserName == "" || inputData.Password == "" {
+			abortWithError(c, validationError("user_name and password are required", nil))
+			return
+		}
+
+		free, err := model.IsLoginFree(c.Request.Context(), inputData.UserName)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err})
+			abortWithError(c, err)
+			return
+		}
+		if !free {
+			abortWithError(c, conflictError("User with login "+inputData.UserName+" already exists"))
+			return
 		}
 
-		if free := model.IsLoginFree(inputData.UserName); !free {
-			c.String(http.StatusConflict, "User with login %s already exists", inputData.UserName)
+		id, err := model.Create(c.Request.Context(), inputData)
+		if err != nil {
+			abortWithError(c, err)
+			return
 		}
 
-		id := model.Create(inputData)
-		user := model.Get(id)
+		user, err := model.Get(c.Request.Context(), id)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusCreated, user)
 	}
 }
 
-func LogInUser(model models.IUserCreator) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.String(http.StatusOK, "")
-	}
-}
-
 func GetSelf(model models.IUserGetter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		any, _ := c.Get(gin.AuthUserKey)
-		selfID, _ := any.(int64)
+		user, err := model.Get(c.Request.Context(), selfID(c))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
-		user := model.Get(selfID)
 		c.JSON(http.StatusOK, user)
 	}
 }
 
 func ListUsers(model models.IUserLister) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
-		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		page, ok := pageRequest(c)
+		if !ok {
+			return
+		}
 		decodedSearchQuery, _ := url.QueryUnescape(c.DefaultQuery("search", ""))
 
-		list := model.GetList(limit, offset, decodedSearchQuery)
-		total := model.Count()
+		list, info, err := model.GetList(c.Request.Context(), page, decodedSearchQuery)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		total, err := model.Count(c.Request.Context(), decodedSearchQuery)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"meta": gin.H{
-				"limit":  limit,
-				"offset": offset,
-				"total":  total,
-			},
+			"meta":  pageMeta(page, info, total),
 			"users": list,
 		})
 	}
 }
+
+// UpdateSelf changes the fields of the authenticated user's profile that the body sets.
+func UpdateSelf(model models.IUserEditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.UserUpdateInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if err := model.Update(c.Request.Context(), selfID(c), inputData); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		user, err := model.Get(c.Request.Context(), selfID(c))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// requirePassword aborts with 403 unless password is the authenticated user's current password.
+func requirePassword(c *gin.Context, model models.IPasswordChecker, password string) bool {
+	ok, err := model.CheckUserPassword(c.Request.Context(), selfID(c), password)
+	if err != nil {
+		abortWithError(c, err)
+		return false
+	}
+	if !ok {
+		abortWithError(c, forbiddenError("the password is wrong", nil))
+		return false
+	}
+
+	return true
+}
+
+// ChangePassword sets a new password given the current one. Every refresh token of the user is
+// revoked, so other sessions have to log in again; access tokens stay valid until they expire.
+func ChangePassword(model models.IPasswordChanger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.PasswordInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if !requirePassword(c, model, inputData.CurrentPassword) {
+			return
+		}
+
+		if err := model.SetPassword(c.Request.Context(), selfID(c), inputData.NewPassword); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
+
+// DeleteSelf deletes the authenticated user's account, confirmed with their password. The courses
+// they own are handed to a teacher or deleted as owned_courses says, see models.ModelUser.Delete.
+func DeleteSelf(model models.IAccountDeleter, issuer *tokens.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inputData := models.AccountDeleteInput{}
+		if !bindJSON(c, &inputData) {
+			return
+		}
+
+		if !requirePassword(c, model, inputData.Password) {
+			return
+		}
+
+		if err := model.Delete(c.Request.Context(), selfID(c), inputData.OwnedCourses); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		issuer.RevokeUser(selfID(c))
+
+		c.JSON(http.StatusOK, gin.H{})
+	}
+}
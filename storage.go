@@ -0,0 +1,88 @@
+package main
+
+import (
+	"coursify-api/routes"
+	"coursify-api/storage"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// newStorage builds the blob storage from the environment:
+//
+//	STORAGE_BACKEND     local (default) or s3
+//	STORAGE_DIR         directory of the local backend, file_storage by default
+//	STORAGE_PUBLIC_URL  base of the public file URLs, http://localhost:8080/fs by default for the local backend
+//	S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY  settings of the s3 backend
+//
+// The local backend's files are served by the API itself under /fs/.
+func newStorage() (storage.Storage, *storage.Local) {
+	publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "file_storage"
+		}
+		if publicURL == "" {
+			publicURL = "http://localhost:8080/fs"
+		}
+
+		local := storage.NewLocal(dir, publicURL)
+		return local, local
+	case "s3":
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+
+		s3 := storage.NewS3(
+			os.Getenv("S3_ENDPOINT"), region, os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), publicURL,
+		)
+		if s3.Endpoint == "" || s3.Bucket == "" {
+			log.Fatal("S3_ENDPOINT and S3_BUCKET must be set for the s3 storage backend")
+		}
+
+		return s3, nil
+	default:
+		log.Fatal("STORAGE_BACKEND must be local or s3")
+		return nil, nil
+	}
+}
+
+// newImageOptions reads the image upload settings from the environment:
+//
+//	IMAGE_MAX_SIZE         largest accepted image in bytes, 10 MiB by default
+//	IMAGE_THUMBNAIL_SIZES  comma separated thumbnail sizes in pixels, 64,256,1024 by default
+func newImageOptions() routes.ImageOptions {
+	opts := routes.ImageOptions{MaxSize: 10 << 20, ThumbnailSizes: []int{64, 256, 1024}}
+
+	if value := os.Getenv("IMAGE_MAX_SIZE"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 || size > routes.MaxUploadSize {
+			log.Fatalf("IMAGE_MAX_SIZE must be a number of bytes up to %d", routes.MaxUploadSize)
+		}
+		opts.MaxSize = size
+	}
+
+	if value := os.Getenv("IMAGE_THUMBNAIL_SIZES"); value != "" {
+		opts.ThumbnailSizes = nil
+		seen := make(map[int]bool)
+
+		for _, field := range strings.Split(value, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || size <= 0 || size > 4096 {
+				log.Fatal("IMAGE_THUMBNAIL_SIZES must list sizes between 1 and 4096 pixels")
+			}
+			if !seen[size] {
+				seen[size] = true
+				opts.ThumbnailSizes = append(opts.ThumbnailSizes, size)
+			}
+		}
+	}
+
+	return opts
+}
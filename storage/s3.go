@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in a bucket of an S3 compatible service. Requests are signed with AWS Signature
+// Version 4 and use path-style addressing, which MinIO and other stand-ins accept as well.
+type S3 struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com or http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// BaseURL is where objects are publicly readable. It defaults to the bucket URL.
+	BaseURL string
+
+	Client *http.Client
+}
+
+func NewS3(endpoint, region, bucket, accessKey, secretKey, baseURL string) *S3 {
+	if baseURL == "" {
+		baseURL = joinURL(endpoint, bucket)
+	}
+
+	return &S3{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		BaseURL:   baseURL,
+		Client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// S3Error is an error response of the service.
+type S3Error struct {
+	Status int
+	Body   string
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3: status %d: %s", e.Status, e.Body)
+}
+
+// uriEncode percent-encodes every byte of s outside the unreserved characters, the way Signature
+// Version 4 expects the path to be written. url.PathEscape leaves characters such as "!" and "(" as
+// they are, which the service encodes before checking the signature.
+func uriEncode(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+
+	return b.String()
+}
+
+func (s *S3) objectURL(key string) string {
+	escaped := make([]string, 0)
+	for _, part := range strings.Split(key, "/") {
+		escaped = append(escaped, uriEncode(part))
+	}
+
+	return s.Endpoint + "/" + uriEncode(s.Bucket) + "/" + strings.Join(escaped, "/")
+}
+
+// unsignedPayload stands in for the payload hash of uploads, so that their bodies can be streamed
+// instead of being read in whole to be hashed first.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// do signs and sends a request for the object with a body of the given size, which may be nil.
+func (s *S3) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := sha256Hex(nil)
+	if body != nil {
+		req.Body = ioutil.NopCloser(body)
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &S3Error{resp.StatusCode, string(msg)}
+	}
+
+	return resp, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, io.LimitReader(data, size), size, contentType)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.BaseURL, key)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// sign adds the Signature Version 4 headers to the request.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	date := now.Format("20060102")
+	stamp := now.Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signed = []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	}
+
+	canonicalHeaders := ""
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += name + ":" + strings.TrimSpace(value) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signed, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		stamp,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, strings.Join(signed, ";"), signature,
+	))
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "eu-central-1"
+	testBucket    = "coursify"
+)
+
+var authPattern = regexp.MustCompile(
+	`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`,
+)
+
+// fakeS3 stands in for the service. It keeps objects in memory and answers 403 to requests whose
+// signature it can't reproduce from what arrived over the wire.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	paths   []string
+}
+
+// verify rebuilds the signature of the request from its Authorization header and the request as
+// received, and reports what is wrong with it.
+func (f *fakeS3) verify(r *http.Request) string {
+	m := authPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil {
+		return "malformed Authorization header " + r.Header.Get("Authorization")
+	}
+	if m[1] != testAccessKey || m[3] != testRegion {
+		return "wrong credential scope " + m[0]
+	}
+
+	stamp := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(stamp, m[2]) {
+		return "X-Amz-Date " + stamp + " doesn't match the credential date " + m[2]
+	}
+
+	signed := strings.Split(m[4], ";")
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		found := false
+		for _, name := range signed {
+			found = found || name == required
+		}
+		if !found {
+			return "header " + required + " is not signed"
+		}
+	}
+
+	canonicalHeaders := ""
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders += name + ":" + strings.TrimSpace(value) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders,
+		m[4],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	scope := m[2] + "/" + testRegion + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", stamp, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+testSecretKey), m[2])
+	key = hmacSHA256(key, testRegion)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); want != m[5] {
+		return "signature " + m[5] + ", want " + want
+	}
+
+	return ""
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.paths = append(f.paths, r.URL.EscapedPath())
+
+	if problem := f.verify(r); problem != "" {
+		f.t.Errorf("%s %s: %s", r.Method, r.URL.EscapedPath(), problem)
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+			f.t.Errorf("PUT payload hash %q, want %q", r.Header.Get("X-Amz-Content-Sha256"), unsignedPayload)
+		}
+		if r.ContentLength < 0 || len(r.TransferEncoding) > 0 {
+			f.t.Errorf("PUT body sent chunked, want a Content-Length")
+		}
+
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			f.t.Errorf("reading PUT body: %v", err)
+		}
+		if int64(len(data)) != r.ContentLength {
+			f.t.Errorf("PUT body is %d bytes, Content-Length says %d", len(data), r.ContentLength)
+		}
+		f.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3(t *testing.T) (*S3, *fakeS3) {
+	fake := &fakeS3{t: t, objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return NewS3(server.URL, testRegion, testBucket, testAccessKey, testSecretKey, ""), fake
+}
+
+// onlyReader hides everything but Read, so that the client can't learn the size from the reader.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (o onlyReader) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func TestS3PutStreamsBody(t *testing.T) {
+	s3, fake := newTestS3(t)
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("coursify"), 64<<10)
+	// The reader holds more than size bytes, and only size of them belong to the object.
+	body := onlyReader{io.MultiReader(bytes.NewReader(data), strings.NewReader("trailing garbage"))}
+
+	if err := s3.Put(ctx, "files/big.bin", body, int64(len(data)), "application/octet-stream"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	stored := fake.objects["/"+testBucket+"/files/big.bin"]
+	if !bytes.Equal(stored, data) {
+		t.Fatalf("stored %d bytes, want the %d bytes put", len(stored), len(data))
+	}
+
+	r, err := s3.Open(ctx, "files/big.bin")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer r.Close()
+
+	read, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading object: %v", err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatalf("read %d bytes back, want %d", len(read), len(data))
+	}
+}
+
+func TestS3MissingObject(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+
+	if _, err := s3.Open(ctx, "files/missing.txt"); err != ErrNotFound {
+		t.Fatalf("Open of a missing object: %v, want ErrNotFound", err)
+	}
+	if err := s3.Delete(ctx, "files/missing.txt"); err != nil {
+		t.Fatalf("Delete of a missing object: %v, want nil", err)
+	}
+}
+
+func TestS3EncodesKeys(t *testing.T) {
+	s3, fake := newTestS3(t)
+	ctx := context.Background()
+
+	key := "files/report (final)!*'~ü.txt"
+	if err := s3.Put(ctx, key, strings.NewReader("done"), 4, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	want := "/" + testBucket + "/files/report%20%28final%29%21%2A%27~%C3%BC.txt"
+	for _, path := range fake.paths {
+		if path != want {
+			t.Errorf("request path %s, want %s", path, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("object not found")
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Storage keeps blobs under slash separated keys such as "images/<uuid>.png".
+type Storage interface {
+	// Put stores size bytes read from data under key, replacing any existing object.
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error
+	// Open returns the content of the object, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is the public address the object can be downloaded from.
+	URL(key string) string
+}
+
+// checkKey rejects keys that could escape the storage root or that backends would read differently.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
+
+// joinURL appends the key to a base URL.
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}
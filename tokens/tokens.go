@@ -0,0 +1,143 @@
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("malformed token")
+	ErrSignature = errors.New("invalid token signature")
+	ErrExpired   = errors.New("token expired")
+	ErrRevoked   = errors.New("token revoked")
+)
+
+type claims struct {
+	UserID    int64 `json:"sub"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// Issuer signs and verifies stateless access tokens and generates opaque refresh tokens.
+type Issuer struct {
+	secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+
+	// revoked holds users whose access tokens are refused, until the tokens issued before have expired.
+	mu      sync.Mutex
+	revoked map[int64]time.Time
+}
+
+func NewIssuer(secret string, accessTTL, refreshTTL time.Duration) *Issuer {
+	return &Issuer{
+		secret:     []byte(secret),
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+		revoked:    make(map[int64]time.Time),
+	}
+}
+
+// RevokeUser refuses the access tokens already issued to the user, e.g. once their account is
+// deleted. The list lives in memory and only needs to outlast AccessTTL, so nothing is looked up
+// in the database to verify a token.
+func (i *Issuer) RevokeUser(userID int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	for id, until := range i.revoked {
+		if !now.Before(until) {
+			delete(i.revoked, id)
+		}
+	}
+
+	i.revoked[userID] = now.Add(i.AccessTTL)
+}
+
+func (i *Issuer) isRevoked(userID int64) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	until, ok := i.revoked[userID]
+
+	return ok && time.Now().Before(until)
+}
+
+func (i *Issuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AccessToken returns a signed access token for userID and the time it expires.
+func (i *Issuer) AccessToken(userID int64) (string, time.Time, error) {
+	expiresAt := time.Now().Add(i.AccessTTL)
+
+	data, err := json.Marshal(claims{userID, expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + i.sign(payload), expiresAt, nil
+}
+
+// Verify checks the signature and expiry of an access token and returns the user id it was issued for.
+// Tokens of users revoked with RevokeUser fail with ErrRevoked.
+func (i *Issuer) Verify(token string) (int64, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return 0, ErrMalformed
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(i.sign(parts[0]))) {
+		return 0, ErrSignature
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, ErrMalformed
+	}
+
+	var c claims
+	if err = json.Unmarshal(data, &c); err != nil {
+		return 0, ErrMalformed
+	}
+
+	if time.Now().Unix() >= c.ExpiresAt {
+		return 0, ErrExpired
+	}
+
+	if i.isRevoked(c.UserID) {
+		return 0, ErrRevoked
+	}
+
+	return c.UserID, nil
+}
+
+// RefreshToken returns a random opaque refresh token and the time it expires.
+func (i *Issuer) RefreshToken() (string, time.Time, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), time.Now().Add(i.RefreshTTL), nil
+}
+
+// Hash returns the digest under which a refresh token is stored, so raw tokens never reach the database.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}